@@ -14,16 +14,31 @@ type (
 		Data ConfigData
 
 		configLocation string
+
+		// onChange is called with the new data whenever the config is changed from the UI
+		onChange []func(ConfigData)
 	}
 
 	ConfigData struct {
-		SelectedMap      string   `json:"selectedMap"`
-		ChatLogDirectory string   `json:"chatLogDirectory"`
+		SelectedMap string `json:"selectedMap"`
+		// ChatLogDirectories are the EVE log directories to watch, one for each Documents folder or Wine prefix
+		ChatLogDirectories []string `json:"chatLogDirectories"`
+		// ChatLogDirectory is only read from older config files, it is moved into ChatLogDirectories on load
+		ChatLogDirectory string   `json:"chatLogDirectory,omitempty"`
 		Channels         []string `json:"channels"`
 		ClearWords       []string `json:"clearWords"`
+		// BackfillMinutes is how many minutes of existing chat logs are read on startup
+		BackfillMinutes int `json:"backfillMinutes"`
+		// StaleChannelMinutes is how long an intel channel can be quiet before a warning is shown, zero disables it
+		StaleChannelMinutes int `json:"staleChannelMinutes"`
 	}
 )
 
+const (
+	defaultBackfillMinutes     = 10
+	defaultStaleChannelMinutes = 30
+)
+
 func NewConfig() *Config {
 	return &Config{}
 }
@@ -61,11 +76,21 @@ func (cfg *Config) LoadConfig() error {
 	defer f.Close()
 
 	dec := json.NewDecoder(f)
-	cd := ConfigData{}
+	// Fields missing from older config files keep their defaults
+	cd := ConfigData{
+		BackfillMinutes:     defaultBackfillMinutes,
+		StaleChannelMinutes: defaultStaleChannelMinutes,
+	}
 	err = dec.Decode(&cd)
 	if err != nil {
 		return err
 	}
+	if cd.ChatLogDirectory != "" {
+		if len(cd.ChatLogDirectories) == 0 {
+			cd.ChatLogDirectories = []string{cd.ChatLogDirectory}
+		}
+		cd.ChatLogDirectory = ""
+	}
 
 	cfg.Data = cd
 	cfg.configLocation = loc
@@ -97,10 +122,15 @@ func (cfg *Config) CreateDefaultConfig(path string) error {
 
 	// Set the defaults for the config
 	cd := ConfigData{
-		SelectedMap:      "Providence",
-		ChatLogDirectory: cfg.logDirHint(),
-		Channels:         []string{"int.testing", "asdf"},
-		ClearWords:       []string{"clear", "clr", "blue"},
+		SelectedMap:         "Providence",
+		ChatLogDirectories:  []string{},
+		Channels:            []string{"int.testing", "asdf"},
+		ClearWords:          []string{"clear", "clr", "blue"},
+		BackfillMinutes:     defaultBackfillMinutes,
+		StaleChannelMinutes: defaultStaleChannelMinutes,
+	}
+	if hint := cfg.logDirHint(); hint != "" {
+		cd.ChatLogDirectories = append(cd.ChatLogDirectories, hint)
 	}
 
 	enc := json.NewEncoder(f)
@@ -112,16 +142,41 @@ func (cfg *Config) CreateDefaultConfig(path string) error {
 
 func (cfg *Config) SetConfig(s map[string]interface{}) error {
 
-	cd := ConfigData{}
+	// Start from the current config so that settings the UI doesn't send are kept
+	cd := cfg.Data
 
-	err := mapstructure.Decode(s, &cd)
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ZeroFields:       true,
+		WeaklyTypedInput: true,
+		Result:           &cd,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = dec.Decode(s)
 	if err != nil {
 		return err
 	}
 
 	cfg.Data = cd
 
-	return cfg.SaveConfig()
+	err = cfg.SaveConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range cfg.onChange {
+		fn(cd)
+	}
+
+	return nil
+}
+
+// OnChange registers a function to be called whenever the config is changed, so new settings can be applied
+// without a restart
+func (cfg *Config) OnChange(fn func(ConfigData)) {
+	cfg.onChange = append(cfg.onChange, fn)
 }
 
 func (cfg *Config) GetData() (string, error) {
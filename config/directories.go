@@ -7,6 +7,10 @@ import (
 )
 
 func (cfg *Config) logDirHint() string {
+	// Prefer a directory the client is actually writing to
+	if candidates := cfg.DiscoverLogDirectories(); len(candidates) > 0 {
+		return candidates[0].Path
+	}
 
 	switch runtime.GOOS {
 	case "windows":
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+type (
+	// LogDirCandidate is an EVE log directory found on this machine
+	LogDirCandidate struct {
+		Path string `json:"path"`
+		// Source describes where the directory was found, such as "steam" or "wine"
+		Source string `json:"source"`
+		// LastActivity is the time the newest chat log in the directory was written
+		LastActivity time.Time `json:"lastActivity"`
+	}
+
+	logDirPattern struct {
+		source string
+		// glob is relative to the users home directory
+		glob string
+	}
+)
+
+const (
+	// eveSteamAppID is the Steam app id of EVE Online, which names its Proton prefix
+	eveSteamAppID = "8500"
+)
+
+var (
+	// The client has used both "EVE" and "Eve", and older Wine prefixes use "My Documents"
+	eveLogs       = filepath.Join("[Ee][Vv][Ee]", "logs")
+	wineDocuments = filepath.Join("drive_c", "users", "*", "*[Dd]ocuments", eveLogs)
+	protonPrefix  = filepath.Join("steamapps", "compatdata", eveSteamAppID, "pfx", wineDocuments)
+
+	steamLibraryPath = regexp.MustCompile(`"path"\s+"([^"]+)"`)
+
+	linuxLogDirs = []logDirPattern{
+		{"steam", filepath.Join(".steam", "steam", protonPrefix)},
+		{"steam", filepath.Join(".local", "share", "Steam", protonPrefix)},
+		{"steam", filepath.Join(".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam", protonPrefix)},
+		{"wine", filepath.Join(".wine", wineDocuments)},
+		{"wine", filepath.Join(".eve", "wineenv", wineDocuments)},
+		{"lutris", filepath.Join("Games", "*", wineDocuments)},
+		{"lutris", filepath.Join(".local", "share", "lutris", "prefixes", "*", wineDocuments)},
+	}
+
+	darwinLogDirs = []logDirPattern{
+		{"macos", filepath.Join("Documents", eveLogs)},
+		{"macos", filepath.Join("Library", "Application Support", "EVE Online", "p_drive", "User", "My Documents", eveLogs)},
+		{"steam", filepath.Join("Library", "Application Support", "Steam", protonPrefix)},
+	}
+
+	windowsLogDirs = []logDirPattern{
+		{"windows", filepath.Join("Documents", eveLogs)},
+		{"windows", filepath.Join("OneDrive", "Documents", eveLogs)},
+	}
+)
+
+// DiscoverLogDirectories looks for EVE log directories in all of the places the client is known to write them,
+// including Steam/Proton, Wine and Lutris prefixes. Only directories that look like real log directories are
+// returned, with the most recently used first.
+func (cfg *Config) DiscoverLogDirectories() []LogDirCandidate {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var patterns []logDirPattern
+	switch runtime.GOOS {
+	case "windows":
+		patterns = windowsLogDirs
+	case "darwin":
+		patterns = darwinLogDirs
+	default:
+		patterns = append(append(patterns, linuxLogDirs...), steamLibraryLogDirs(home)...)
+	}
+
+	var lf feeds.LogFeed
+	seen := make(map[string]bool)
+	candidates := make([]LogDirCandidate, 0)
+
+	for _, p := range patterns {
+		matches, err := filepath.Glob(filepath.Join(home, p.glob))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			// Steam links several of its paths together, so only report each directory once
+			real, err := filepath.EvalSymlinks(m)
+			if err != nil || seen[real] {
+				continue
+			}
+			seen[real] = true
+
+			if !lf.CheckLogDir(m) {
+				continue
+			}
+
+			candidates = append(candidates, LogDirCandidate{
+				Path:         m,
+				Source:       p.source,
+				LastActivity: lastChatlog(m),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].LastActivity.After(candidates[j].LastActivity)
+	})
+
+	return candidates
+}
+
+// steamLibraryLogDirs finds the Proton prefixes in any extra Steam library folders
+func steamLibraryLogDirs(home string) (patterns []logDirPattern) {
+	vdf := filepath.Join(home, ".steam", "steam", "steamapps", "libraryfolders.vdf")
+	b, err := ioutil.ReadFile(vdf)
+	if err != nil {
+		return nil
+	}
+
+	for _, m := range steamLibraryPath.FindAllStringSubmatch(string(b), -1) {
+		rel, err := filepath.Rel(home, m[1])
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, logDirPattern{"steam", filepath.Join(rel, protonPrefix)})
+	}
+
+	return patterns
+}
+
+// lastChatlog returns the time the newest chat log in the directory was written
+func lastChatlog(dir string) (last time.Time) {
+	files, err := ioutil.ReadDir(filepath.Join(dir, "Chatlogs"))
+	if err != nil {
+		return time.Time{}
+	}
+
+	for _, f := range files {
+		if f.ModTime().After(last) {
+			last = f.ModTime()
+		}
+	}
+
+	return last
+}
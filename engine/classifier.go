@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+const (
+	// maxPilotCount is the largest count of pilots believed, bigger numbers are more likely to be something else
+	maxPilotCount = 500
+)
+
+var (
+	// countNouns follow a number to say that it is a count of pilots, as in "5 neuts"
+	countNouns = []string{"man", "men", "ships", "pilots", "neuts", "neutrals", "reds", "hostiles", "guys", "wt",
+		"wts"}
+	// groupNouns come before "of" and a count of pilots, as in "gang of 10"
+	groupNouns = []string{"gang", "fleet", "group", "squad"}
+
+	noVisualWords = []string{"nv", "novis", "novisual"}
+	statusWords   = []string{"status", "stat", "stats", "statu"}
+	bubbleWords   = []string{"bubble", "bubbled", "bubbles", "bubs", "bub", "drag", "dragbubble", "hictor", "dictor"}
+	campWords     = []string{"camp", "camped", "camping", "gatecamp", "gatecamped", "campers"}
+	spikeWords    = []string{"spike", "spiked", "spiking"}
+)
+
+// Classify works out what an intel message says from its tokens. The systems and ships are those matched in it, and
+// the clear words are the user's words for a system being clear.
+func Classify(msg string, tokens []Token, systems []SystemMatch, ships []ShipMatch, clearWords []string) feeds.Intel {
+	intel := feeds.Intel{
+		PilotNames: ExtractPilots(msg, tokens, systems, ships, clearWords),
+	}
+	for _, m := range systems {
+		if !containsSystem(intel.Systems, m.SystemID) {
+			intel.Systems = append(intel.Systems, m.SystemID)
+		}
+	}
+
+	found := make([]Ship, 0, len(ships))
+	for _, m := range ships {
+		found = append(found, m.Ship)
+		if !containsString(intel.Ships, m.Name) {
+			intel.Ships = append(intel.Ships, m.Name)
+		}
+	}
+	sort.Strings(intel.Ships)
+	intel.Threat = string(WorstThreat(found))
+
+	// shipAt marks the words that start the name of a ship, so that "3 dreads" is a count
+	shipAt := make(map[int]bool)
+	words := make([]string, 0, len(tokens))
+	for i, tok := range tokens {
+		for _, m := range ships {
+			if m.Start == tok.Start {
+				shipAt[i] = true
+			}
+		}
+
+		if tok.Kind == TokenQuoted {
+			// Pilot names can be anything at all
+			words = append(words, "")
+			continue
+		}
+		words = append(words, strings.ToLower(tok.Text))
+	}
+
+	for i, w := range words {
+		if w == "" {
+			continue
+		}
+
+		if n, ok := pilotCount(words, i); ok && n > intel.Pilots {
+			intel.Pilots = n
+		}
+		if n, ok := parseCount(w); ok && shipAt[i+1] && n > intel.Pilots {
+			intel.Pilots = n
+		}
+
+		for _, cw := range clearWords {
+			if w == strings.ToLower(cw) {
+				intel.Clear = true
+			}
+		}
+
+		switch {
+		case containsString(noVisualWords, w),
+			w == "no" && i+1 < len(words) && strings.HasPrefix(words[i+1], "vis"):
+			intel.NoVisual = true
+		case containsString(statusWords, w):
+			intel.StatusRequest = true
+		case containsString(bubbleWords, w):
+			intel.Bubble = true
+		case containsString(campWords, w),
+			w == "gate" && i+1 < len(words) && strings.HasPrefix(words[i+1], "camp"):
+			intel.Camp = true
+		case containsString(spikeWords, w):
+			intel.Spike = true
+		}
+	}
+
+	// Asking after a system with nothing else, as in "1DQ?", is asking for its status
+	if !intel.Hostile() && !intel.Clear && strings.HasSuffix(strings.TrimSpace(msg), "?") {
+		intel.StatusRequest = true
+	}
+
+	return intel
+}
+
+// pilotCount reads a count of pilots starting at word i, as in "+5", "5x", "x5", "5 neuts" or "gang of 10"
+func pilotCount(words []string, i int) (int, bool) {
+	w := words[i]
+
+	switch {
+	case strings.HasPrefix(w, "+"):
+		return parseCount(w[1:])
+	case strings.HasSuffix(w, "x"):
+		return parseCount(strings.TrimSuffix(w, "x"))
+	case strings.HasPrefix(w, "x"):
+		return parseCount(strings.TrimPrefix(w, "x"))
+	case containsString(groupNouns, w) && i+2 < len(words) && words[i+1] == "of":
+		return parseCount(words[i+2])
+	case i+1 < len(words) && containsString(countNouns, words[i+1]):
+		return parseCount(w)
+	}
+
+	return 0, false
+}
+
+func parseCount(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > maxPilotCount {
+		return 0, false
+	}
+	return n, true
+}
+
+// intelStatus is the status intel gives its systems. It is false when the intel doesn't change their status, such as
+// when asking for it.
+func intelStatus(intel feeds.Intel) (uint8, bool) {
+	switch {
+	case intel.Pilots > 0 || len(intel.Ships) > 0:
+		return 2, true
+	case intel.Clear:
+		// A bubble or camp that is reported clear has gone
+		return 1, true
+	case intel.Hostile():
+		return 2, true
+	case intel.StatusRequest, intel.NoVisual:
+		return 0, false
+	}
+	// Anyone mentioned in intel without saying otherwise is hostile
+	return 2, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
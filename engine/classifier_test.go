@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+func TestClassify(t *testing.T) {
+	ie := newTestEngine(t)
+	ie.SetClearWords([]string{"clr", "clear"})
+
+	tests := []struct {
+		msg    string
+		want   feeds.Intel
+		status uint8
+	}{
+		{"1DQ1-A +5", feeds.Intel{Systems: []int32{30004759}, Pilots: 5}, 2},
+		{"1DQ1-A 5x sabre", feeds.Intel{
+			Systems: []int32{30004759}, Pilots: 5, Ships: []string{"Sabre"}, Threat: string(ThreatTackle),
+		}, 2},
+		{"1DQ1-A x5 rifter", feeds.Intel{
+			Systems: []int32{30004759}, Pilots: 5, Ships: []string{"Rifter"}, Threat: string(ThreatCombat),
+		}, 2},
+		{"1DQ1-A gang of 10", feeds.Intel{Systems: []int32{30004759}, Pilots: 10}, 2},
+		{"10 neuts 49-U6U", feeds.Intel{Systems: []int32{30004760}, Pilots: 10}, 2},
+		{"status 1DQ?", feeds.Intel{Systems: []int32{30004759}, StatusRequest: true}, 0},
+		{"1DQ1-A?", feeds.Intel{Systems: []int32{30004759}, StatusRequest: true}, 0},
+		{"nv", feeds.Intel{NoVisual: true}, 0},
+		{"49-U6U no vis", feeds.Intel{Systems: []int32{30004760}, NoVisual: true}, 0},
+		{"MJ- +5 sabre bubble", feeds.Intel{
+			Systems: []int32{30004761}, Pilots: 5, Ships: []string{"Sabre"}, Threat: string(ThreatTackle), Bubble: true,
+		}, 2},
+		{"T5ZI-S gate camp", feeds.Intel{Systems: []int32{30004762}, Camp: true}, 2},
+		{"1DQ1-A spike", feeds.Intel{Systems: []int32{30004759}, Spike: true}, 2},
+		{"1DQ1-A clr", feeds.Intel{Systems: []int32{30004759}, Clear: true}, 1},
+		{"MJ-5F9 bubble clear", feeds.Intel{Systems: []int32{30004761}, Bubble: true, Clear: true}, 1},
+	}
+
+	for _, tt := range tests {
+		rep := feeds.Report{Message: tt.msg, Reporter: "Scout", Time: time.Now()}
+		ie.checkReport(&rep)
+
+		if rep.Intel == nil {
+			t.Errorf("%q has no intel", tt.msg)
+			continue
+		}
+		got := *rep.Intel
+		// Nothing found is the same as none found
+		if len(got.Systems) == 0 {
+			got.Systems = nil
+		}
+		if len(got.PilotNames) == 0 {
+			got.PilotNames = nil
+		}
+		if len(got.Ships) == 0 {
+			got.Ships = nil
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q\n got %+v\nwant %+v", tt.msg, got, tt.want)
+		}
+		if rep.Status != tt.status {
+			t.Errorf("%q has status %d, want %d", tt.msg, rep.Status, tt.status)
+		}
+	}
+}
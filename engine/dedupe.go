@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+const (
+	// duplicateWindow is how far apart two copies of the same report can be and still be merged
+	duplicateWindow = 2 * time.Minute
+	// maxReportHistory is how many of the latest reports are kept in memory, every snapshot copies them all
+	maxReportHistory = 1000
+)
+
+// ingest adds a report to the history. A copy of a report that is already there, because several characters heard
+// it or it was cross-posted to another channel, is merged into the original instead and dup is true.
+func (ie *IntelEngine) ingest(rep *feeds.Report) (kept *feeds.Report, dup bool) {
+	if ie.recentReports == nil {
+		ie.recentReports = make(map[string]*feeds.Report)
+	}
+
+	for h, r := range ie.recentReports {
+		if rep.Time.Sub(r.Time) > duplicateWindow {
+			delete(ie.recentReports, h)
+		}
+	}
+
+	h := rep.Hash()
+	if r, ok := ie.recentReports[h]; ok && absDuration(rep.Time.Sub(r.Time)) <= duplicateWindow {
+		r.Merge(rep)
+		log.Printf("DEBUG: IE: merged copy of %q from %s (%s)", rep.Message, rep.Listener, rep.Source)
+		return r, true
+	}
+
+	// Start the lists off with where this copy was heard
+	rep.Merge(rep)
+	ie.reportHistory = append(ie.reportHistory, rep)
+	ie.recentReports[h] = rep
+	ie.trimHistory()
+
+	return rep, false
+}
+
+// trimHistory drops the oldest reports once there are more than maxReportHistory
+func (ie *IntelEngine) trimHistory() {
+	n := len(ie.reportHistory)
+	if n <= maxReportHistory {
+		return
+	}
+
+	// Reuse the array so it doesn't keep growing, and let go of the dropped reports
+	copy(ie.reportHistory, ie.reportHistory[n-maxReportHistory:])
+	for i := maxReportHistory; i < n; i++ {
+		ie.reportHistory[i] = nil
+	}
+	ie.reportHistory = ie.reportHistory[:maxReportHistory]
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// Galaxy is New Eden indexed for fast lookups. It is built once and never changed, so it is safe to share.
+	// The values it returns share their maps with the galaxy and must not be modified.
+	Galaxy struct {
+		eden NewEden
+
+		// systems is every system ordered by id
+		systems []System
+
+		systemsByID    map[int32]int
+		systemsByName  map[string]int
+		systemRegion   map[int32]int32
+		systemConstell map[int32]int32
+		constellations map[int32]Constellation
+		regionsByName  map[string]int32
+		adjacent       map[int32][]int32
+
+		// nameWords is the most words in any system name
+		nameWords int
+	}
+)
+
+var (
+	SystemNotFound        = errors.New("system not found")
+	ConstellationNotFound = errors.New("constellation not found")
+	RegionNotFound        = errors.New("region not found")
+)
+
+// LoadGalaxy loads the embedded map data and indexes it
+func LoadGalaxy() (*Galaxy, error) {
+	ne := make(NewEden)
+	err := ne.LoadData()
+	if err != nil {
+		return nil, err
+	}
+	return NewGalaxy(ne), nil
+}
+
+// NewGalaxy indexes New Eden by system id and name, links every system to its constellation and region, and works
+// out which systems are next to each other
+func NewGalaxy(ne NewEden) *Galaxy {
+	g := &Galaxy{
+		eden:           ne,
+		systems:        make([]System, 0),
+		systemsByID:    make(map[int32]int),
+		systemsByName:  make(map[string]int),
+		systemRegion:   make(map[int32]int32),
+		systemConstell: make(map[int32]int32),
+		constellations: make(map[int32]Constellation),
+		regionsByName:  make(map[string]int32),
+		adjacent:       make(map[int32][]int32),
+	}
+
+	for regionID, region := range ne {
+		g.regionsByName[strings.ToLower(region.Name)] = regionID
+		for constellationID, constellation := range region.Constellations {
+			g.constellations[constellationID] = constellation
+			for systemID, system := range constellation.Systems {
+				g.systems = append(g.systems, system)
+				g.systemRegion[systemID] = regionID
+				g.systemConstell[systemID] = constellationID
+			}
+		}
+	}
+
+	// Sorting first means that any clash in names always goes the same way
+	sort.Slice(g.systems, func(i, j int) bool {
+		return g.systems[i].SystemID < g.systems[j].SystemID
+	})
+
+	for i, system := range g.systems {
+		g.systemsByID[system.SystemID] = i
+		name := strings.ToLower(system.Name)
+		if _, ok := g.systemsByName[name]; !ok {
+			g.systemsByName[name] = i
+		}
+		if n := len(strings.Fields(name)); n > g.nameWords {
+			g.nameWords = n
+		}
+	}
+
+	for _, system := range g.systems {
+		seen := make(map[int32]bool)
+		for _, gate := range system.Stargates {
+			dest := gate.Destination.SystemID
+			// Cant have a system link to itself
+			if dest == system.SystemID || seen[dest] {
+				continue
+			}
+			seen[dest] = true
+			g.adjacent[system.SystemID] = append(g.adjacent[system.SystemID], dest)
+		}
+		sort.Slice(g.adjacent[system.SystemID], func(i, j int) bool {
+			return g.adjacent[system.SystemID][i] < g.adjacent[system.SystemID][j]
+		})
+	}
+
+	return g
+}
+
+// NewEden returns the raw map data the galaxy was built from
+func (g *Galaxy) NewEden() NewEden {
+	return g.eden
+}
+
+// GetSystem finds a system by its id
+func (g *Galaxy) GetSystem(id int32) (System, error) {
+	i, ok := g.systemsByID[id]
+	if !ok {
+		return System{}, SystemNotFound
+	}
+	return g.systems[i], nil
+}
+
+// GetSystemByName finds a system by its exact name, ignoring case
+func (g *Galaxy) GetSystemByName(name string) (System, error) {
+	i, ok := g.systemsByName[strings.ToLower(name)]
+	if !ok {
+		return System{}, SystemNotFound
+	}
+	return g.systems[i], nil
+}
+
+// SystemName returns the proper name of a system given in any case, and false if there is no such system
+func (g *Galaxy) SystemName(name string) (string, bool) {
+	system, err := g.GetSystemByName(name)
+	if err != nil {
+		return "", false
+	}
+	return system.Name, true
+}
+
+// NameWords returns the most words in the name of any system, which is as far ahead as names need to be looked for
+func (g *Galaxy) NameWords() int {
+	return g.nameWords
+}
+
+// Systems returns every system in New Eden, ordered by id
+func (g *Galaxy) Systems() []System {
+	return append([]System(nil), g.systems...)
+}
+
+// Constellation returns the constellation a system is in
+func (g *Galaxy) Constellation(systemID int32) (Constellation, error) {
+	id, ok := g.systemConstell[systemID]
+	if !ok {
+		return Constellation{}, SystemNotFound
+	}
+	c, ok := g.constellations[id]
+	if !ok {
+		return Constellation{}, ConstellationNotFound
+	}
+	return c, nil
+}
+
+// Region returns the region a system is in
+func (g *Galaxy) Region(systemID int32) (Region, error) {
+	id, ok := g.systemRegion[systemID]
+	if !ok {
+		return Region{}, SystemNotFound
+	}
+	r, ok := g.eden[id]
+	if !ok {
+		return Region{}, RegionNotFound
+	}
+	return r, nil
+}
+
+// GetRegionByName finds a region by its exact name, ignoring case
+func (g *Galaxy) GetRegionByName(name string) (Region, error) {
+	id, ok := g.regionsByName[strings.ToLower(name)]
+	if !ok {
+		return Region{}, fmt.Errorf("%w: %s", RegionNotFound, name)
+	}
+	return g.eden[id], nil
+}
+
+// Adjacent returns the systems one jump away from a system, ordered by id. It must not be modified.
+func (g *Galaxy) Adjacent(systemID int32) []int32 {
+	return g.adjacent[systemID]
+}
+
+// JumpsFrom returns how many jumps it takes to reach every system within max jumps of a system, including itself
+func (g *Galaxy) JumpsFrom(systemID int32, max int) map[int32]int {
+	jumps := map[int32]int{systemID: 0}
+	frontier := []int32{systemID}
+	for d := 1; d <= max && len(frontier) > 0; d++ {
+		next := make([]int32, 0)
+		for _, s := range frontier {
+			for _, n := range g.adjacent[s] {
+				if _, ok := jumps[n]; ok {
+					continue
+				}
+				jumps[n] = d
+				next = append(next, n)
+			}
+		}
+		frontier = next
+	}
+	return jumps
+}
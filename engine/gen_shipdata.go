@@ -0,0 +1,389 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	UniverseCategory struct {
+		CategoryID int32   `json:"category_id"`
+		Groups     []int32 `json:"groups"`
+		Name       string  `json:"name"`
+		Published  bool    `json:"published"`
+	}
+
+	UniverseGroup struct {
+		GroupID   int32   `json:"group_id"`
+		Name      string  `json:"name"`
+		Published bool    `json:"published"`
+		Types     []int32 `json:"types"`
+	}
+
+	UniverseType struct {
+		GroupID   int32  `json:"group_id"`
+		Name      string `json:"name"`
+		Published bool   `json:"published"`
+		TypeID    int32  `json:"type_id"`
+	}
+
+	//	These are the types I want to write to file!
+
+	ShipData struct {
+		Groups    map[int32]ShipGroup     `json:"groups"`
+		Types     map[int32]ShipType      `json:"types"`
+		Nicknames map[string]ShipNickname `json:"nicknames"`
+	}
+
+	ShipGroup struct {
+		GroupID int32  `json:"group_id"`
+		Name    string `json:"name"`
+		Class   string `json:"class"`
+	}
+
+	ShipType struct {
+		GroupID int32  `json:"group_id"`
+		Name    string `json:"name"`
+		TypeID  int32  `json:"type_id"`
+	}
+
+	ShipNickname struct {
+		GroupID int32 `json:"group_id,omitempty"`
+		TypeID  int32 `json:"type_id,omitempty"`
+	}
+)
+
+const (
+	shipCategoryID = 6
+
+	urlUniverseCategory = "https://esi.evetech.net/v1/universe/categories/%d/"
+	urlUniverseGroup    = "https://esi.evetech.net/v1/universe/groups/%d/"
+	urlUniverseType     = "https://esi.evetech.net/v3/universe/types/%d/"
+)
+
+var (
+	// groupClasses sorts the ship groups by the threat they pose, any group not listed is a combat ship
+	groupClasses = map[string]string{
+		"Interceptor":                "tackle",
+		"Interdictor":                "tackle",
+		"Heavy Interdiction Cruiser": "tackle",
+		"Electronic Attack Ship":     "tackle",
+		"Stealth Bomber":             "bomber",
+		"Covert Ops":                 "covert",
+		"Force Recon Ship":           "covert",
+		"Black Ops":                  "covert",
+		"Strategic Cruiser":          "covert",
+		"Expedition Frigate":         "covert",
+		"Carrier":                    "capital",
+		"Dreadnought":                "capital",
+		"Lancer Dreadnought":         "capital",
+		"Force Auxiliary":            "capital",
+		"Supercarrier":               "capital",
+		"Titan":                      "capital",
+		"Capital Industrial Ship":    "capital",
+		"Industrial":                 "hauler",
+		"Blockade Runner":            "hauler",
+		"Deep Space Transport":       "hauler",
+		"Freighter":                  "hauler",
+		"Jump Freighter":             "hauler",
+		"Shuttle":                    "hauler",
+		"Mining Barge":               "mining",
+		"Exhumer":                    "mining",
+		"Industrial Command Ship":    "mining",
+		"Capsule":                    "pod",
+	}
+
+	// groupNicknames are the names pilots use for whole classes of ship
+	groupNicknames = map[string]string{
+		"dictor":   "Interdictor",
+		"dic":      "Interdictor",
+		"hic":      "Heavy Interdiction Cruiser",
+		"hictor":   "Heavy Interdiction Cruiser",
+		"inty":     "Interceptor",
+		"ceptor":   "Interceptor",
+		"bomber":   "Stealth Bomber",
+		"t3c":      "Strategic Cruiser",
+		"t3":       "Strategic Cruiser",
+		"t3d":      "Tactical Destroyer",
+		"dread":    "Dreadnought",
+		"fax":      "Force Auxiliary",
+		"super":    "Supercarrier",
+		"blops":    "Black Ops",
+		"recon":    "Force Recon Ship",
+		"cov ops":  "Covert Ops",
+		"covops":   "Covert Ops",
+		"hac":      "Heavy Assault Cruiser",
+		"logi":     "Logistics",
+		"bs":       "Battleship",
+		"bc":       "Battlecruiser",
+		"dessie":   "Destroyer",
+		"barge":    "Mining Barge",
+		"jf":       "Jump Freighter",
+		"dst":      "Deep Space Transport",
+		"pod":      "Capsule",
+		"hauler":   "Industrial",
+		"marauder": "Marauder",
+		"eaf":      "Electronic Attack Ship",
+		"rorq":     "Capital Industrial Ship",
+		"rorqual":  "Capital Industrial Ship",
+	}
+
+	// typeNicknames are the names pilots use for single ships
+	typeNicknames = map[string]string{
+		"cane":    "Hurricane",
+		"phoon":   "Typhoon",
+		"mach":    "Machariel",
+		"vaga":    "Vagabond",
+		"cyna":    "Cynabal",
+		"lachi":   "Lachesis",
+		"vni":     "Vexor Navy Issue",
+		"hfi":     "Hurricane Fleet Issue",
+		"tfi":     "Typhoon Fleet Issue",
+		"sni":     "Scorpion Navy Issue",
+		"rni":     "Raven Navy Issue",
+		"ani":     "Armageddon Navy Issue",
+		"dni":     "Dominix Navy Issue",
+		"cni":     "Caracal Navy Issue",
+		"mega":    "Megathron",
+		"domi":    "Dominix",
+		"geddon":  "Armageddon",
+		"apoc":    "Apocalypse",
+		"mael":    "Maelstrom",
+		"nid":     "Nidhoggur",
+		"naggy":   "Naglfar",
+		"rev":     "Revelation",
+		"rag":     "Ragnarok",
+		"levi":    "Leviathan",
+		"stratty": "Stratios",
+		"kiki":    "Kikimora",
+		"hugin":   "Huginn",
+		"svip":    "Svipul",
+	}
+)
+
+func main() {
+
+	log.Println("Removing generated files")
+
+	clearGenFiles()
+
+	log.Println("Starting Ship Data Download")
+
+	client := http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	var category UniverseCategory
+	err := GetJson(fmt.Sprintf(urlUniverseCategory, shipCategoryID), client, &category)
+	if err != nil {
+		log.Fatal(fmt.Errorf("failed to get ship category: %w", err))
+	}
+
+	//	Now pull in the groups
+	log.Println("Starting Groups Download")
+	workers := 16
+	groupJobs := make(chan int32, 256)
+	groupResults := make(chan UniverseGroup, 64)
+
+	for w := 1; w <= workers; w++ {
+		go groupWorker(groupJobs, groupResults, client)
+	}
+
+	jobcnt := 0
+
+	for _, g := range category.Groups {
+		groupJobs <- g
+		jobcnt++
+	}
+	close(groupJobs)
+
+	universeGroups := make(map[int32]UniverseGroup, jobcnt)
+	for c := 0; c < jobcnt; c++ {
+		g := <-groupResults
+		if !g.Published {
+			continue
+		}
+		universeGroups[g.GroupID] = g
+	}
+	close(groupResults)
+
+	log.Printf("Fetched %d groups\n", jobcnt)
+
+	//	Now pull in the types
+	log.Println("Starting Types Download")
+	workers = 64
+	typeJobs := make(chan int32, 2048)
+	typeResults := make(chan UniverseType, 256)
+
+	for w := 1; w <= workers; w++ {
+		go typeWorker(typeJobs, typeResults, client)
+	}
+
+	jobcnt = 0
+
+	for _, g := range universeGroups {
+		for _, t := range g.Types {
+			typeJobs <- t
+			jobcnt++
+		}
+	}
+	close(typeJobs)
+
+	universeTypes := make(map[int32]UniverseType, jobcnt)
+	for c := 0; c < jobcnt; c++ {
+		t := <-typeResults
+		if !t.Published {
+			continue
+		}
+		universeTypes[t.TypeID] = t
+	}
+	close(typeResults)
+
+	log.Printf("Fetched %d types\n", jobcnt)
+
+	log.Println("Sorting the fleet")
+
+	sd := ShipData{
+		Groups:    make(map[int32]ShipGroup, len(universeGroups)),
+		Types:     make(map[int32]ShipType, len(universeTypes)),
+		Nicknames: make(map[string]ShipNickname),
+	}
+
+	groupsByName := make(map[string]int32)
+	for _, g := range universeGroups {
+		class, ok := groupClasses[g.Name]
+		if !ok {
+			class = "combat"
+		}
+		sd.Groups[g.GroupID] = ShipGroup{
+			GroupID: g.GroupID,
+			Name:    g.Name,
+			Class:   class,
+		}
+		groupsByName[g.Name] = g.GroupID
+	}
+
+	typesByName := make(map[string]int32)
+	for _, t := range universeTypes {
+		sd.Types[t.TypeID] = ShipType{
+			GroupID: t.GroupID,
+			Name:    t.Name,
+			TypeID:  t.TypeID,
+		}
+		typesByName[t.Name] = t.TypeID
+	}
+
+	for nick, name := range groupNicknames {
+		id, ok := groupsByName[name]
+		if !ok {
+			log.Printf("WARN: no ship group %s for nickname %s", name, nick)
+			continue
+		}
+		sd.Nicknames[strings.ToLower(nick)] = ShipNickname{GroupID: id}
+	}
+	for nick, name := range typeNicknames {
+		id, ok := typesByName[name]
+		if !ok {
+			log.Printf("WARN: no ship %s for nickname %s", name, nick)
+			continue
+		}
+		sd.Nicknames[strings.ToLower(nick)] = ShipNickname{TypeID: id}
+	}
+
+	// Save the ship data to json
+	f, err := os.OpenFile("ships.json", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer f.Close()
+	bf := bufio.NewWriter(f)
+	enc := json.NewEncoder(bf)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(sd)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	bf.Flush()
+	f.Sync()
+
+	log.Println("DONE!")
+}
+
+func clearGenFiles() {
+	files := []string{"ships.json"}
+
+	for _, f := range files {
+		err := os.Remove(f)
+		if err != nil {
+			log.Printf("WARN: %s", err.Error())
+		}
+	}
+}
+
+func groupWorker(jobs <-chan int32, results chan<- UniverseGroup, client http.Client) {
+	for id := range jobs {
+		var g UniverseGroup
+		err := GetJson(fmt.Sprintf(urlUniverseGroup, id), client, &g)
+		if err != nil {
+			log.Fatalln(fmt.Errorf("failed to query group %d: %w", id, err))
+		}
+		results <- g
+	}
+}
+
+func typeWorker(jobs <-chan int32, results chan<- UniverseType, client http.Client) {
+	for id := range jobs {
+		var t UniverseType
+		err := GetJson(fmt.Sprintf(urlUniverseType, id), client, &t)
+		if err != nil {
+			log.Fatalln(fmt.Errorf("failed to query type %d: %w", id, err))
+		}
+		results <- t
+	}
+}
+
+func GetJson(url string, client http.Client, dest interface{}) (err error) {
+	retries := 8
+	for retries > 0 {
+		retries--
+
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("User-Agent", "Crypta Electrica - Spyglass Ship Gen")
+		res, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to make request: %w", err)
+		}
+		if res.Body != nil {
+			defer res.Body.Close()
+		}
+		if res.StatusCode != http.StatusOK {
+			// If needed, log this
+			continue
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		err = json.Unmarshal(body, dest)
+		if err != nil {
+			return fmt.Errorf("failed to decode json: body: %s: %w", string(body), err)
+		}
+		return nil
+	}
+
+	return errors.New(fmt.Sprintf("retries exceeded: url %s", url))
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+type (
+	// ChannelHealth describes how recently an intel channel was heard from, by each listener and overall
+	ChannelHealth struct {
+		Channel     string           `json:"channel"`
+		LastMessage time.Time        `json:"lastMessage"`
+		Stale       bool             `json:"stale"`
+		Listeners   []ListenerHealth `json:"listeners"`
+	}
+
+	// ListenerHealth describes how recently a single listener heard from an intel channel
+	ListenerHealth struct {
+		Listener    string    `json:"listener"`
+		LastMessage time.Time `json:"lastMessage"`
+		Stale       bool      `json:"stale"`
+	}
+
+	heardKey struct {
+		source   string
+		listener string
+	}
+)
+
+// AddActivitySource registers a feed whose channels are checked for going quiet
+func (ie *IntelEngine) AddActivitySource(src feeds.ActivitySource) {
+	ie.healthMu.Lock()
+	defer ie.healthMu.Unlock()
+	ie.activitySources = append(ie.activitySources, src)
+}
+
+// SetStaleAfter sets how long a channel can go without any messages before it is flagged as stale.
+// Zero disables the check.
+func (ie *IntelEngine) SetStaleAfter(d time.Duration) {
+	ie.healthMu.Lock()
+	defer ie.healthMu.Unlock()
+	ie.staleAfter = d
+}
+
+// heard records a report arriving, so that channels are only flagged once their reports stop reaching the engine
+func (ie *IntelEngine) heard(rep *feeds.Report) {
+	ie.healthMu.Lock()
+	defer ie.healthMu.Unlock()
+
+	if ie.lastHeard == nil {
+		ie.lastHeard = make(map[heardKey]time.Time)
+	}
+	k := heardKey{source: rep.Source, listener: rep.Listener}
+	if rep.Time.After(ie.lastHeard[k]) {
+		ie.lastHeard[k] = rep.Time
+	}
+}
+
+// ChannelHealth returns the state of every watched intel channel, ordered by name
+func (ie *IntelEngine) ChannelHealth(now time.Time) []ChannelHealth {
+	ie.healthMu.Lock()
+	defer ie.healthMu.Unlock()
+
+	stale := func(t time.Time) bool {
+		return ie.staleAfter > 0 && now.Sub(t) > ie.staleAfter
+	}
+
+	channels := make(map[string]*ChannelHealth)
+	for _, src := range ie.activitySources {
+		for _, a := range src.Activity() {
+			last := a.LastMessage
+			if t := ie.lastHeard[heardKey{source: a.Source, listener: a.Listener}]; t.After(last) {
+				last = t
+			}
+
+			ch, ok := channels[a.Channel]
+			if !ok {
+				ch = &ChannelHealth{Channel: a.Channel}
+				channels[a.Channel] = ch
+			}
+			ch.Listeners = append(ch.Listeners, ListenerHealth{
+				Listener:    a.Listener,
+				LastMessage: last,
+				Stale:       stale(last),
+			})
+			if last.After(ch.LastMessage) {
+				ch.LastMessage = last
+			}
+		}
+	}
+
+	list := make([]ChannelHealth, 0, len(channels))
+	for _, ch := range channels {
+		ch.Stale = stale(ch.LastMessage)
+		list = append(list, *ch)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Channel < list[j].Channel
+	})
+
+	return list
+}
+
+// Warnings describes every stale channel, and every listener that has stopped hearing a channel that is still active
+func (ie *IntelEngine) Warnings() []string {
+	now := time.Now()
+
+	warnings := make([]string, 0)
+	for _, ch := range ie.ChannelHealth(now) {
+		if ch.Stale {
+			warnings = append(warnings, fmt.Sprintf("intel channel %s has been quiet for %s", ch.Channel, quietFor(now, ch.LastMessage)))
+			continue
+		}
+		for _, l := range ch.Listeners {
+			if l.Stale {
+				warnings = append(warnings, fmt.Sprintf("%s has not heard from %s for %s", l.Listener, ch.Channel, quietFor(now, l.LastMessage)))
+			}
+		}
+	}
+
+	return warnings
+}
+
+func quietFor(now, t time.Time) time.Duration {
+	return now.Sub(t).Truncate(time.Minute)
+}
@@ -8,9 +8,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/agext/levenshtein"
 	"github.com/eve-spyglass/spyglass2/feeds"
 	"github.com/sirupsen/logrus"
 	"gonum.org/v1/gonum/graph/simple"
@@ -18,21 +19,55 @@ import (
 
 type (
 	IntelEngine struct {
-		Galaxy           NewEden
-		CurrentMap       string
-		monitoredSystems []int32
+		Galaxy     *Galaxy
+		CurrentMap string
 
-		clearWords []string
+		// matcher finds systems in reports, which are matched against the whole galaxy whatever map is being shown
+		matcher *Matcher
+		ships   *Ships
 
-		reportHistory   []*feeds.Report
-		locationHistory []*feeds.Locstat
+		// clearMu guards clearWords, which can be changed from the UI while reports are being checked
+		clearMu    sync.RWMutex
+		clearWords []string
 
-		currentStatus map[int32]uint8
-		lastUpdated   map[int32]time.Time
+		// The intel state below is owned by the listener goroutine and must only be touched from there, everything
+		// else reads it through the latest snapshot and changes it by sending a command
+		monitoredSystems []int32
+		reportHistory    []*feeds.Report
+		locationHistory  []*feeds.Locstat
+		// characterLocations holds the system each character was last seen in
+		characterLocations map[string]int32
+		currentStatus      map[int32]uint8
+		lastUpdated        map[int32]time.Time
+		// systemShips holds the ships last reported in each system that is not clear
+		systemShips map[int32][]string
+		// hostiles holds every pilot reported recently, by their lower case name
+		hostiles map[string]*Hostile
+
+		// recentReports holds the latest reports by their hash, so that copies of them can be merged
+		recentReports map[string]*feeds.Report
+
+		// snapshot holds the latest *Snapshot of the intel state
+		snapshot atomic.Value
+		commands chan func()
+
+		// lifeMu guards the lifecycle of the listener goroutine. While it is not running, the state is owned by
+		// whoever holds lifeMu.
+		lifeMu  sync.Mutex
+		running bool
+		cancel  context.CancelFunc
+		// stopped is closed when the listener goroutine has finished
+		stopped chan struct{}
 
 		locationInput chan feeds.Locstat
 		intelInput    chan feeds.Report
 
+		// healthMu guards the channel health tracking, which is read from the UI
+		healthMu        sync.Mutex
+		activitySources []feeds.ActivitySource
+		lastHeard       map[heardKey]time.Time
+		staleAfter      time.Duration
+
 		mapGraph *simple.UndirectedGraph
 	}
 
@@ -47,23 +82,65 @@ type (
 		// it returns a string array where each string represents a connection
 		// it will be formatted as "1234-5678" and is directional from source to sink
 		GetJumps() []string
+		// Ships returns the ships last reported in each system
+		Ships() map[int32][]string
 		// GetFeeders will return the two channels that can e used to feed information into the resource
 		GetFeeders() (chan<- feeds.Report, chan<- feeds.Locstat, error)
 	}
+
+	systemName struct {
+		id int32
+		// lower is the name as it is compared, see normalizeName
+		lower string
+	}
+
+	// Snapshot is a consistent copy of the intel state at one moment. A snapshot is never changed once it has been
+	// taken, so it can be read from any goroutine, and readers must not change it either.
+	Snapshot struct {
+		Taken       time.Time
+		Monitored   []int32
+		Status      map[int32]uint8
+		LastUpdated map[int32]time.Time
+		Ships       map[int32][]string
+		Hostiles    []Hostile
+		// History holds the latest reports, oldest first
+		History []feeds.Report
+	}
+)
+
+const (
+	// minMatchConfidence is how sure a match of a system in a report must be to change its status
+	minMatchConfidence = 0.6
 )
 
-func NewIntelEngine(ctx context.Context) (*IntelEngine, error) {
+var (
+	EngineStopped = errors.New("intel engine has stopped")
+	EngineRunning = errors.New("intel engine is already running")
+)
 
-	galaxy := make(NewEden)
-	err := galaxy.LoadData()
+// NewIntelEngine loads the galaxy and prepares an engine, reports are not processed until Start is called
+func NewIntelEngine() (*IntelEngine, error) {
+
+	galaxy, err := LoadGalaxy()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load galaxy data: %w", err)
 	}
 
+	ships, err := LoadShips()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ship data: %w", err)
+	}
+
 	ie := &IntelEngine{
-		Galaxy:     galaxy,
-		CurrentMap: "Delve",
+		Galaxy:        galaxy,
+		CurrentMap:    "Delve",
+		matcher:       NewMatcher(galaxy),
+		ships:         ships,
+		currentStatus: make(map[int32]uint8),
+		lastUpdated:   make(map[int32]time.Time),
+		commands:      make(chan func()),
 	}
+	ie.publish()
 
 	err = ie.updateMapGraph()
 	if err != nil {
@@ -76,11 +153,6 @@ func NewIntelEngine(ctx context.Context) (*IntelEngine, error) {
 	ie.intelInput = reps
 	ie.locationInput = locs
 
-	err = ie.startListeners(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start intel listeners")
-	}
-
 	return ie, nil
 }
 
@@ -90,128 +162,354 @@ func (ie *IntelEngine) SetCurrentMap(m string) error {
 }
 
 func (ie *IntelEngine) SetClearWords(words []string) {
-	ie.clearWords = words
+	ie.clearMu.Lock()
+	defer ie.clearMu.Unlock()
+	ie.clearWords = append([]string(nil), words...)
 }
 
+// updateMapGraph builds the graph of the current map, and hands it to the goroutine that owns the intel state
 func (ie *IntelEngine) updateMapGraph() error {
 	// TODO change this to account for non region mapdefs
 	//	Find the correct region based on the current selected map
-	for _, r := range ie.Galaxy {
-		if r.Name == ie.CurrentMap {
-			// This is us!
-			ie.mapGraph = simple.NewUndirectedGraph()
-
-			for _, c := range r.Constellations {
-				for _, s := range c.Systems {
-					for _, g := range s.Stargates {
-						// Cant have a system link to itself
-						if s.SystemID == g.Destination.SystemID {
-							continue
-						}
-
-						ie.mapGraph.SetEdge(ie.mapGraph.NewEdge(simple.Node(s.SystemID), simple.Node(g.Destination.SystemID)))
-					}
-				}
-			}
+	r, err := ie.Galaxy.GetRegionByName(ie.CurrentMap)
+	if err != nil {
+		return errors.New("map not found")
+	}
 
-			return nil
+	g := simple.NewUndirectedGraph()
+	for _, c := range r.Constellations {
+		for _, s := range c.Systems {
+			for _, dest := range ie.Galaxy.Adjacent(s.SystemID) {
+				g.SetEdge(g.NewEdge(simple.Node(s.SystemID), simple.Node(dest)))
+			}
 		}
 	}
-	return errors.New("map not found")
+
+	return ie.do(func() {
+		ie.mapGraph = g
+	})
 }
 
-func (ie *IntelEngine) startListeners(ctx context.Context) error {
-
-	go func() {
-		log.Println("DEBUG: IE: Starting to Listen")
-		for {
-			select {
-			case rep := <-ie.intelInput:
-				// Received a new intel report
-				ie.reportHistory = append(ie.reportHistory, &rep)
-				ie.checkReport(&rep)
-				log.Printf("IE: Got Intel - %s", rep.Message)
-
-			case loc := <-ie.locationInput:
-				// Received a new location report
-				log.Printf("IE - Got locstat - %s", loc.Character)
-			case <-ctx.Done():
-				panic(1)
-				// return
+// Start runs the goroutine that owns the intel state until Stop is called or ctx is cancelled
+func (ie *IntelEngine) Start(ctx context.Context) error {
+	ie.lifeMu.Lock()
+	defer ie.lifeMu.Unlock()
+
+	if ie.running {
+		return EngineRunning
+	}
+
+	ctx, ie.cancel = context.WithCancel(ctx)
+	ie.stopped = make(chan struct{})
+	ie.running = true
+
+	go ie.run(ctx, ie.stopped)
+
+	return nil
+}
+
+// Stop finishes processing any reports that have already arrived and waits for the engine to stop
+func (ie *IntelEngine) Stop() error {
+	ie.lifeMu.Lock()
+	if !ie.running {
+		ie.lifeMu.Unlock()
+		return EngineStopped
+	}
+	cancel, stopped := ie.cancel, ie.stopped
+	ie.lifeMu.Unlock()
+
+	cancel()
+	<-stopped
+
+	return nil
+}
+
+// run owns the intel state. Reports are applied as they arrive, and a new snapshot is published once there are none
+// waiting, so a burst of reports does not publish a snapshot for each one.
+func (ie *IntelEngine) run(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	log.Println("DEBUG: IE: Starting to Listen")
+	for {
+		select {
+		case rep := <-ie.intelInput:
+			ie.apply(rep)
+			if len(ie.intelInput) == 0 {
+				ie.publish()
 			}
+
+		case loc := <-ie.locationInput:
+			// Received a new location report
+			log.Printf("IE - Got locstat - %s", loc.Character)
+			ie.locate(loc)
+		case cmd := <-ie.commands:
+			cmd()
+		case <-ctx.Done():
+			ie.drain()
+
+			ie.lifeMu.Lock()
+			ie.running = false
+			ie.lifeMu.Unlock()
+
+			log.Println("IE: Stopped listening")
+			return
 		}
-	}()
+	}
+}
+
+// drain applies the reports that arrived before the engine was stopped
+func (ie *IntelEngine) drain() {
+	for {
+		select {
+		case rep := <-ie.intelInput:
+			ie.apply(rep)
+		default:
+			ie.publish()
+			return
+		}
+	}
+}
+
+// apply takes in a new intel report
+func (ie *IntelEngine) apply(rep feeds.Report) {
+	ie.heard(&rep)
+	kept, dup := ie.ingest(&rep)
+	if !dup {
+		ie.checkReport(kept)
+		log.Printf("IE: Got Intel - %s", rep.Message)
+	}
+}
+
+// locate records where a character is, which helps tell apart systems with similar names in their reports
+func (ie *IntelEngine) locate(loc feeds.Locstat) {
+	system, err := ie.Galaxy.GetSystemByName(loc.System)
+	if err != nil {
+		return
+	}
+	if ie.characterLocations == nil {
+		ie.characterLocations = make(map[string]int32)
+	}
+	ie.characterLocations[loc.Character] = system.SystemID
+}
+
+// do runs fn on the goroutine that owns the intel state and waits for the resulting snapshot to be published.
+// When the engine is not running fn is run straight away instead.
+func (ie *IntelEngine) do(fn func()) error {
+	ie.lifeMu.Lock()
+	if !ie.running {
+		defer ie.lifeMu.Unlock()
+		fn()
+		ie.publish()
+		return nil
+	}
+	stopped := ie.stopped
+	ie.lifeMu.Unlock()
+
+	done := make(chan struct{})
+	cmd := func() {
+		fn()
+		ie.publish()
+		close(done)
+	}
+
+	select {
+	case ie.commands <- cmd:
+	case <-stopped:
+		return EngineStopped
+	}
+	<-done
 
 	return nil
 }
 
-func (ie *IntelEngine) GetIntelMessages() []string {
+// publish takes a new snapshot of the intel state, it must only be called from the goroutine that owns the state
+func (ie *IntelEngine) publish() {
+	s := &Snapshot{
+		Taken:       time.Now(),
+		Monitored:   append([]int32(nil), ie.monitoredSystems...),
+		Status:      make(map[int32]uint8, len(ie.currentStatus)),
+		LastUpdated: make(map[int32]time.Time, len(ie.lastUpdated)),
+		Ships:       make(map[int32][]string, len(ie.systemShips)),
+		History:     make([]feeds.Report, len(ie.reportHistory)),
+	}
+	for k, v := range ie.currentStatus {
+		s.Status[k] = v
+	}
+	for k, v := range ie.lastUpdated {
+		s.LastUpdated[k] = v
+	}
+	for k, v := range ie.systemShips {
+		s.Ships[k] = append([]string(nil), v...)
+	}
+	s.Hostiles = make([]Hostile, 0, len(ie.hostiles))
+	for _, h := range ie.hostiles {
+		c := *h
+		c.Onward = append([]int32(nil), h.Onward...)
+		c.Sightings = append([]Sighting(nil), h.Sightings...)
+		s.Hostiles = append(s.Hostiles, c)
+	}
+	for i, r := range ie.reportHistory {
+		// Merging copies into a report adds to these, so they can't be shared
+		s.History[i] = *r
+		s.History[i].Listeners = append([]string(nil), r.Listeners...)
+		s.History[i].Sources = append([]string(nil), r.Sources...)
+	}
+	sort.SliceStable(s.History, func(i, j int) bool {
+		return s.History[i].Time.Before(s.History[j].Time)
+	})
 
-	rl := feeds.ReportList(ie.reportHistory)
-	sort.Sort(rl)
+	ie.snapshot.Store(s)
+}
 
-	strngs := make([]string, rl.Len())
-	for i := range rl {
-		strngs[i] = rl[i].String()
+// Snapshot returns the latest copy of the intel state
+func (ie *IntelEngine) Snapshot() *Snapshot {
+	return ie.snapshot.Load().(*Snapshot)
+}
+
+func (ie *IntelEngine) GetIntelMessages() []string {
+	history := ie.Snapshot().History
+
+	strngs := make([]string, len(history))
+	for i := range history {
+		strngs[i] = history[i].String()
 	}
 
 	return strngs
 }
 
+// checkReport updates the status of every system the report mentions, anywhere in the galaxy. The monitored systems
+// only decide what is shown, intel about everywhere else is kept for when the map changes.
 func (ie *IntelEngine) checkReport(rep *feeds.Report) {
-	// Now we need to check each part of the message for potential matches to monitored system names.
-	msgParts := strings.Split(rep.Message, " ")
+	// Some feeds, such as the game logs, already know exactly where the report is from and who it is about
+	var intel feeds.Intel
+	if rep.System != "" || rep.Attacker != "" {
+		intel = ie.feedIntel(rep)
+	} else {
+		intel = ie.messageIntel(rep)
+	}
+	systems := intel.Systems
+	rep.Intel = &intel
+
+	status, changes := intelStatus(intel)
+	rep.Status = status
+	if !changes {
+		logrus.Debugf("IE - %q does not change the status of %v", rep.Message, systems)
+		return
+	}
 
-	// TODO: Make these configurable
-	const dist = 0.8
-	var ignores = []string{"in", "as", "is"}
+	if ie.systemShips == nil {
+		ie.systemShips = make(map[int32][]string)
+	}
+	for _, sys := range systems {
+		ie.currentStatus[sys] = status
+		ie.lastUpdated[sys] = rep.Time
 
-	var systems []int32
+		// Ships are kept until they are replaced or the system is reported clear
+		switch {
+		case status == 1:
+			delete(ie.systemShips, sys)
+		case len(intel.Ships) > 0:
+			ie.systemShips[sys] = intel.Ships
+		}
+	}
 
-	rep.Status = 2
+	if status == 2 && len(systems) > 0 && len(intel.PilotNames) > 0 {
+		ie.sight(intel.PilotNames, systems, rep.Time)
+	}
+}
 
-	for _, word := range msgParts {
-		lowerWord := strings.ToLower(word)
-		for _, i := range ignores {
-			if lowerWord == strings.ToLower(i) {
-				continue
-			}
+// matchContext is what the engine knows about where a report is from
+func (ie *IntelEngine) matchContext(rep *feeds.Report) MatchContext {
+	ctx := MatchContext{
+		OnMap: make(map[int32]bool, len(ie.monitoredSystems)),
+	}
+	for _, s := range ie.monitoredSystems {
+		ctx.OnMap[s] = true
+	}
+	for _, character := range []string{rep.Reporter, rep.Listener} {
+		if s, ok := ie.characterLocations[character]; ok {
+			ctx.Near = append(ctx.Near, s)
 		}
-		for _, s := range ie.monitoredSystems {
-			system, err := ie.Galaxy.GetSystem(s)
-			if err != nil {
-				continue
-			}
+	}
+	return ctx
+}
 
-			// D will be in a range of 0 to 1, where 1 is a perfect match
-			d := levenshtein.Match(lowerWord, strings.ToLower(system.Name), levenshtein.NewParams().BonusPrefix(3).BonusThreshold(0.3).BonusScale(0.21))
-			log.Printf("DEBUG: IE: MATCHER %s to %s with a distance of %.2f", word, system.Name, d)
-			if d >= dist {
-				// We have a system match here! Yay, intel!
-				log.Printf("DEBUG: IE: Matched %s to %s with a distance of %.2f", word, system.Name, d)
-				systems = append(systems, system.SystemID)
-				break
-			}
+// feedIntel is the intel in a report from a feed that saw for itself where it happened and who did it. The message
+// only describes it, so it isn't matched, as the names of pilots and ships are often also the names of systems.
+func (ie *IntelEngine) feedIntel(rep *feeds.Report) feeds.Intel {
+	intel := feeds.Intel{Systems: make([]int32, 0, 1)}
+
+	switch {
+	case rep.System != "":
+		if system, err := ie.Galaxy.GetSystemByName(rep.System); err == nil {
+			intel.Systems = append(intel.Systems, system.SystemID)
+		}
+	case rep.Attacker != "":
+		// The listener was attacked wherever they are
+		if s, ok := ie.characterLocations[rep.Listener]; ok {
+			intel.Systems = append(intel.Systems, s)
 		}
+	}
 
-		for _, cw := range ie.clearWords {
-			logrus.Debug("IE - Checking %s vs %s for CW", lowerWord, strings.ToLower(cw))
-			if lowerWord == strings.ToLower(cw) {
-				logrus.Debug("MATCHED CLEAR WORD %s to %s", lowerWord, strings.ToLower(cw))
-				rep.Status = 1
-			}
+	if rep.Attacker != "" {
+		intel.Pilots = 1
+		intel.PilotNames = []string{rep.Attacker}
+	}
+	if ship, ok := ie.ships.lookup(strings.ToLower(rep.Ship)); ok {
+		intel.Ships = []string{ship.Name}
+		intel.Threat = string(WorstThreat([]Ship{ship}))
+	}
+
+	return intel
+}
+
+// messageIntel is the intel in a report that is only known from its message
+func (ie *IntelEngine) messageIntel(rep *feeds.Report) feeds.Intel {
+	tokens := Tokenize(rep.Message)
+
+	ie.clearMu.RLock()
+	clearWords := ie.clearWords
+	ie.clearMu.RUnlock()
+
+	systems := make([]int32, 0)
+	var matched []SystemMatch
+	for _, m := range ie.matcher.Match(tokens, ie.matchContext(rep)) {
+		if m.Confidence < minMatchConfidence {
+			log.Printf("DEBUG: IE: Ignored %s as %d, confidence %.2f", m.Text, m.SystemID, m.Confidence)
+			continue
+		}
+		// We have a system match here! Yay, intel!
+		log.Printf("DEBUG: IE: Matched %s to %d, confidence %.2f", m.Text, m.SystemID, m.Confidence)
+		matched = append(matched, m)
+		if !containsSystem(systems, m.SystemID) {
+			systems = append(systems, m.SystemID)
 		}
 	}
 
-	for _, sys := range systems {
-		ie.currentStatus[sys] = rep.Status
-		ie.lastUpdated[sys] = rep.Time
+	// A word that was taken as a system can't be a ship as well
+	var ships []ShipMatch
+ships:
+	for _, sm := range ie.ships.Match(tokens) {
+		for _, m := range matched {
+			if sm.Start < m.End && m.Start < sm.End {
+				continue ships
+			}
+		}
+		ships = append(ships, sm)
 	}
 
+	intel := Classify(rep.Message, tokens, matched, ships, clearWords)
+	intel.Systems = systems
+	return intel
+}
+
+// LoadAliases reads the user's own names for systems from a file, see Matcher.LoadAliases
+func (ie *IntelEngine) LoadAliases(path string) error {
+	return ie.matcher.LoadAliases(path)
 }
 
 func (ie *IntelEngine) IsSystemMonitored(sys int32) bool {
-	for _, s := range ie.monitoredSystems {
+	for _, s := range ie.Snapshot().Monitored {
 		if s == sys {
 			return true
 		}
@@ -221,43 +519,39 @@ func (ie *IntelEngine) IsSystemMonitored(sys int32) bool {
 
 // The following methods are to satisfy the IntelResource interface
 
-// Status returns a map of systems to status, where true is hostile and false is clear
+// Status returns a map of systems to status, where true is hostile and false is clear. It holds every system in the
+// galaxy with any intel, not just the monitored ones, and is taken from the latest snapshot so must not be modified.
 func (ie *IntelEngine) Status() map[int32]uint8 {
-	// m := make(map[int32]bool, len(ie.monitoredSystems))
-	// for _, v := range ie.monitoredSystems {
-	// 	m[v] = rand.Float32() > 0.5
-	// }
-
-	// return m
+	return ie.Snapshot().Status
+}
 
-	return ie.currentStatus
+// Ships returns the ships last reported in each system, by name.
+// It is taken from the latest snapshot and must not be modified.
+func (ie *IntelEngine) Ships() map[int32][]string {
+	return ie.Snapshot().Ships
 }
 
-// LastUpdated returns the time since any information was received about a system
+// LastUpdated returns the time since any information was received about a system.
+// It is taken from the latest snapshot and must not be modified.
 func (ie *IntelEngine) LastUpdated() map[int32]time.Time {
-	// t := make(map[int32]time.Time, len(ie.monitoredSystems))
-	// for _, v := range ie.monitoredSystems {
-	// 	t[v] = time.Now().Add(-1 * time.Second * time.Duration(rand.Intn(300)))
-	// }
-	// return t
-
-	return ie.lastUpdated
+	return ie.Snapshot().LastUpdated
 }
 
-//	SetSystems will notify the IntelResource which systems to monitor for intel
+// SetSystems will notify the IntelResource which systems to show and alarm on, intel is kept for every system
 func (ie *IntelEngine) SetMonitoredSystems(systems []int32) error {
-	ie.monitoredSystems = make([]int32, len(systems))
-	ie.currentStatus = make(map[int32]uint8, len(systems))
-	ie.lastUpdated = make(map[int32]time.Time, len(systems))
-
+	monitored := make([]int32, 0, len(systems))
 	for _, system := range systems {
 		sys, err := ie.Galaxy.GetSystem(system)
 		if err != nil {
 			continue
 		}
-		ie.monitoredSystems = append(ie.monitoredSystems, sys.SystemID)
+		monitored = append(monitored, sys.SystemID)
 	}
-	return nil
+
+	// The status of every system is kept, so nothing is lost when switching maps
+	return ie.do(func() {
+		ie.monitoredSystems = monitored
+	})
 }
 
 // GetJumps will return the connections between the monitored systems
@@ -266,16 +560,16 @@ func (ie *IntelEngine) GetJumps() []string {
 	// TODO find a way to preallocate this to some extent
 	jumps := make([]string, 0)
 
-	for _, s := range ie.monitoredSystems {
-		source, err := ie.Galaxy.GetSystem(s)
-		if err != nil {
-			// TODO this shouldnt ever happen so I probably shouldnt be silent here but will do for now
-			continue
-		}
+	monitored := ie.Snapshot().Monitored
+	isMonitored := make(map[int32]bool, len(monitored))
+	for _, s := range monitored {
+		isMonitored[s] = true
+	}
 
-		for _, gate := range source.Stargates {
-			if ie.IsSystemMonitored(gate.Destination.SystemID) {
-				jumps = append(jumps, strconv.Itoa(int(source.SystemID))+"-"+strconv.Itoa(int(gate.Destination.SystemID)))
+	for _, s := range monitored {
+		for _, dest := range ie.Galaxy.Adjacent(s) {
+			if isMonitored[dest] {
+				jumps = append(jumps, strconv.Itoa(int(s))+"-"+strconv.Itoa(int(dest)))
 			}
 		}
 	}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+// newTestEngine returns an engine over a small galaxy, showing the Delve map. In Delve 1DQ1-A, 49-U6U, MJ-5F9 and
+// T5ZI-S are a line of systems, Querious holds systems whose names start like counts of pilots, and Domain holds
+// Amarr.
+func newTestEngine(t *testing.T) *IntelEngine {
+	t.Helper()
+
+	system := func(id int32, name string, links ...int32) System {
+		s := System{SystemID: id, Name: name, Stargates: make(map[int32]Stargate)}
+		for i, l := range links {
+			gate := id*10 + int32(i)
+			s.Stargates[gate] = Stargate{StargateID: gate, Destination: StargateDestination{SystemID: l}}
+		}
+		return s
+	}
+	region := func(id int32, name string, systems ...System) Region {
+		c := Constellation{ConstellationID: id + 1, Name: name, Systems: make(map[int32]System)}
+		for _, s := range systems {
+			c.Systems[s.SystemID] = s
+		}
+		return Region{RegionID: id, Name: name, Constellations: map[int32]Constellation{c.ConstellationID: c}}
+	}
+
+	ne := NewEden{
+		10000060: region(10000060, "Delve",
+			system(30004759, "1DQ1-A", 30004760),
+			system(30004760, "49-U6U", 30004759, 30004761),
+			system(30004761, "MJ-5F9", 30004760, 30004762),
+			system(30004762, "T5ZI-S", 30004761),
+		),
+		10000050: region(10000050, "Querious",
+			system(30004980, "5XR-KZ", 30004981),
+			system(30004981, "X5-0EM", 30004980, 30004982),
+			system(30004982, "10UZ-P", 30004981),
+		),
+		10000043: region(10000043, "Domain",
+			system(30002187, "Amarr"),
+		),
+	}
+	sd := ShipData{
+		Groups: map[int32]ShipGroup{
+			25:  {GroupID: 25, Name: "Frigate", Class: ThreatCombat},
+			541: {GroupID: 541, Name: "Interdictor", Class: ThreatTackle},
+		},
+		Types: map[int32]ShipType{
+			587:   {GroupID: 25, Name: "Rifter", TypeID: 587},
+			22456: {GroupID: 541, Name: "Sabre", TypeID: 22456},
+		},
+		Nicknames: map[string]ShipNickname{
+			"dictor": {GroupID: 541},
+		},
+	}
+
+	galaxy := NewGalaxy(ne)
+	ie := &IntelEngine{
+		Galaxy:        galaxy,
+		CurrentMap:    "Delve",
+		matcher:       NewMatcher(galaxy),
+		ships:         NewShips(sd),
+		currentStatus: make(map[int32]uint8),
+		lastUpdated:   make(map[int32]time.Time),
+		commands:      make(chan func()),
+		intelInput:    make(chan feeds.Report, 64),
+		locationInput: make(chan feeds.Locstat, 64),
+	}
+	ie.publish()
+
+	if err := ie.SetCurrentMap("Delve"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ie.SetMonitoredSystems([]int32{30004759, 30004760, 30004761, 30004762}); err != nil {
+		t.Fatal(err)
+	}
+
+	return ie
+}
+
+func TestAttackerReport(t *testing.T) {
+	ie := newTestEngine(t)
+	ie.characterLocations = map[string]int32{"Me": 30004760}
+
+	tests := []struct {
+		rep    feeds.Report
+		system int32
+	}{
+		// Where the listener is known to be
+		{feeds.Report{
+			Message: "Amarr Victor warp scrambling", Listener: "Me", Attacker: "Amarr Victor", Ship: "Sabre",
+		}, 30004760},
+		// Where the game log says the listener is
+		{feeds.Report{
+			Message: "MJ-5F9 Amarr Victor shooting", Listener: "Me", Attacker: "Amarr Victor", Ship: "Sabre", System: "MJ-5F9",
+		}, 30004761},
+	}
+
+	for _, tt := range tests {
+		rep := tt.rep
+		rep.Time = time.Now()
+		ie.checkReport(&rep)
+
+		intel := rep.Intel
+		if len(intel.Systems) != 1 || intel.Systems[0] != tt.system {
+			t.Errorf("%q is in %v, want %d", rep.Message, intel.Systems, tt.system)
+		}
+		if len(intel.PilotNames) != 1 || intel.PilotNames[0] != "Amarr Victor" {
+			t.Errorf("%q is by %v, want Amarr Victor", rep.Message, intel.PilotNames)
+		}
+		if len(intel.Ships) != 1 || intel.Ships[0] != "Sabre" || intel.Threat != string(ThreatTackle) {
+			t.Errorf("%q is in %v, a %s threat, want a Sabre", rep.Message, intel.Ships, intel.Threat)
+		}
+		if rep.Status != 2 {
+			t.Errorf("%q has status %d, want 2", rep.Message, rep.Status)
+		}
+	}
+
+	// The attacker's name is not a report on the system it shares a name with
+	if _, ok := ie.currentStatus[30002187]; ok {
+		t.Errorf("Amarr was given status %d", ie.currentStatus[30002187])
+	}
+}
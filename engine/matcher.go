@@ -0,0 +1,452 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/agext/levenshtein"
+)
+
+type (
+	MatchKind uint8
+
+	// SystemMatch is a system found in an intel message
+	SystemMatch struct {
+		SystemID int32     `json:"systemId"`
+		Kind     MatchKind `json:"kind"`
+		// Text is the part of the message that matched, and Start and End are its byte offsets in the message
+		Text  string `json:"text"`
+		Start int    `json:"start"`
+		End   int    `json:"end"`
+		// Confidence is how sure the match is, from 0 to 1
+		Confidence float64 `json:"confidence"`
+		// Candidates is how many systems the text could equally have meant, after preferring the ones nearby
+		Candidates int `json:"candidates"`
+	}
+
+	// MatchContext is what is known about where a report is from, to choose between systems that match equally well
+	MatchContext struct {
+		// OnMap holds the systems on the map being shown, which are preferred over all others
+		OnMap map[int32]bool
+		// Near holds the systems the reporter, or whoever heard the report, is known to be in. The closest systems to
+		// them are preferred.
+		Near []int32
+	}
+
+	// Matcher finds the systems mentioned in tokenized intel messages. It matches names in full, by the start of a
+	// name, by aliases and finally by spelling, and scores each match by how sure it is.
+	Matcher struct {
+		galaxy *Galaxy
+		trie   *trieNode
+		names  []systemName
+
+		// aliasMu guards the aliases, which can be reloaded while reports are being matched
+		aliasMu    sync.RWMutex
+		aliases    map[string]int32
+		aliasWords int
+	}
+
+	trieNode struct {
+		children map[rune]*trieNode
+		// systems holds every system whose name starts with the path to the node, and exact those whose name ends here
+		systems []int32
+		exact   []int32
+	}
+)
+
+const (
+	MatchLink MatchKind = iota
+	MatchExact
+	MatchAlias
+	MatchPrefix
+	MatchFuzzy
+)
+
+const (
+	// minFuzzyLength is the shortest word that is matched by spelling, shorter words are close to too many systems
+	minFuzzyLength = 5
+	// minFuzzyScore is how close a word must be spelled to a system name to match it at all
+	minFuzzyScore = 0.8
+	// minPrefixLetters is the shortest start of a name that matches, unless it has a digit in it or ends in a dash.
+	// Names like 49-U6U and MJ-5F9 are commonly shortened to just a couple of characters.
+	minPrefixLetters = 4
+	minPrefixDigits  = 2
+	// nearbyJumps is how far from the reporter systems are looked for, when choosing between equal matches
+	nearbyJumps = 15
+	// closeByJumps is how far from the map or the reporter a system can be, to be matched by only a couple of
+	// characters
+	closeByJumps = 3
+)
+
+var (
+	// countWord matches the ways a count of pilots is written on its own, as in "5x", "x5" and "+5"
+	countWord = regexp.MustCompile(`^(\+\d+|\d+x|x\d+)$`)
+
+	// stopWords are common in intel but never systems, however close to a system name they are
+	stopWords = []string{"in", "as", "is", "at", "on", "to", "and", "the", "nv", "no", "gate", "local", "spike", "red",
+		"neut", "clr", "clear", "status"}
+)
+
+// NewMatcher indexes the names of every system in the galaxy
+func NewMatcher(galaxy *Galaxy) *Matcher {
+	m := &Matcher{
+		galaxy:  galaxy,
+		trie:    &trieNode{},
+		names:   galaxySystemNames(galaxy),
+		aliases: make(map[string]int32),
+	}
+
+	for _, s := range galaxy.Systems() {
+		m.trie.insert(normalizeName(s.Name), s.SystemID)
+	}
+
+	return m
+}
+
+func galaxySystemNames(galaxy *Galaxy) []systemName {
+	systems := galaxy.Systems()
+	names := make([]systemName, len(systems))
+	for i, s := range systems {
+		names[i] = systemName{id: s.SystemID, lower: normalizeName(s.Name)}
+	}
+	return names
+}
+
+// LoadAliases reads aliases from a JSON file of alias to system name, such as {"1dq": "1DQ1-A"}. A missing file is
+// the same as an empty one.
+func (m *Matcher) LoadAliases(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m.SetAliases(nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	aliases := make(map[string]string)
+	err = json.Unmarshal(raw, &aliases)
+	if err != nil {
+		return fmt.Errorf("failed to parse aliases: %w", err)
+	}
+
+	return m.SetAliases(aliases)
+}
+
+// SetAliases replaces the aliases with a map of alias to system name. Aliases for systems that don't exist are left
+// out and returned as an error, the rest are still used.
+func (m *Matcher) SetAliases(aliases map[string]string) error {
+	resolved := make(map[string]int32, len(aliases))
+	words := 0
+	unknown := make([]string, 0)
+	for alias, name := range aliases {
+		system, err := m.galaxy.GetSystemByName(strings.TrimSpace(name))
+		if err != nil {
+			unknown = append(unknown, fmt.Sprintf("%s (%s)", alias, name))
+			continue
+		}
+
+		tokens := Tokenize(alias)
+		key, ok := Phrase(tokens, 0, len(tokens))
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("%s (%s)", alias, name))
+			continue
+		}
+		resolved[strings.ToLower(key)] = system.SystemID
+		if len(tokens) > words {
+			words = len(tokens)
+		}
+	}
+
+	m.aliasMu.Lock()
+	m.aliases = resolved
+	m.aliasWords = words
+	m.aliasMu.Unlock()
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("aliases for unknown systems: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Match finds every system mentioned in the tokens of a message
+func (m *Matcher) Match(tokens []Token, ctx MatchContext) []SystemMatch {
+	m.aliasMu.RLock()
+	aliases, aliasWords := m.aliases, m.aliasWords
+	m.aliasMu.RUnlock()
+
+	matches := make([]SystemMatch, 0)
+	found := func(i, n int, kind MatchKind, confidence float64, candidates []int32) {
+		id, count := m.resolve(candidates, ctx)
+		matches = append(matches, SystemMatch{
+			SystemID:   id,
+			Kind:       kind,
+			Text:       tokens[i].Text,
+			Start:      tokens[i].Start,
+			End:        tokens[i+n-1].End,
+			Confidence: confidence / float64(count),
+			Candidates: count,
+		})
+		if n > 1 {
+			matches[len(matches)-1].Text, _ = Phrase(tokens, i, n)
+		}
+	}
+
+tokens:
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch tok.Kind {
+		case TokenQuoted:
+			// Quotes are used for pilot names, which are never systems
+			continue tokens
+
+		case TokenLink:
+			// A link is as good as an exact match, whatever it links to
+			if tok.TypeID == solarSystemTypeID {
+				if _, err := m.galaxy.GetSystem(tok.ItemID); err == nil {
+					found(i, 1, MatchLink, 1, []int32{tok.ItemID})
+					continue tokens
+				}
+			}
+			if system, err := m.galaxy.GetSystemByName(tok.Text); err == nil {
+				found(i, 1, MatchLink, 1, []int32{system.SystemID})
+			}
+			continue tokens
+		}
+
+		// Aliases are chosen by the user, so they come before anything else, the longest one wins
+		for n := aliasWords; n > 0; n-- {
+			phrase, ok := Phrase(tokens, i, n)
+			if !ok {
+				continue
+			}
+			if id, ok := aliases[strings.ToLower(phrase)]; ok {
+				found(i, n, MatchAlias, 0.95, []int32{id})
+				i += n - 1
+				continue tokens
+			}
+		}
+
+		// Names of more than one word have to match exactly, the longest one wins
+		for n := m.galaxy.NameWords(); n > 1; n-- {
+			phrase, ok := Phrase(tokens, i, n)
+			if !ok {
+				continue
+			}
+			if system, err := m.galaxy.GetSystemByName(phrase); err == nil {
+				found(i, n, MatchExact, 1, []int32{system.SystemID})
+				i += n - 1
+				continue tokens
+			}
+		}
+
+		word := normalizeName(tok.Text)
+		if isStopWord(word) || isCountWord(tokens, i) {
+			continue tokens
+		}
+
+		node := m.trie.find(word)
+		if node != nil && len(node.exact) > 0 {
+			found(i, 1, MatchExact, 1, node.exact)
+			continue tokens
+		}
+
+		if node != nil && prefixAllowed(tok.Text) {
+			candidates := node.systems
+			if len(word) <= minPrefixDigits {
+				// So few characters start too many names across the galaxy, only those close by are meant
+				candidates = m.closeBy(candidates, ctx)
+				if len(candidates) == 0 {
+					continue tokens
+				}
+			}
+
+			// The more of the name that was given, the surer the match
+			shortest := 0
+			for _, id := range candidates {
+				s, _ := m.galaxy.GetSystem(id)
+				if l := len(normalizeName(s.Name)); shortest == 0 || l < shortest {
+					shortest = l
+				}
+			}
+			found(i, 1, MatchPrefix, 0.5+0.4*float64(len(word))/float64(shortest), candidates)
+			continue tokens
+		}
+
+		if len(word) >= minFuzzyLength {
+			if candidates, score := m.fuzzy(word); len(candidates) > 0 {
+				found(i, 1, MatchFuzzy, 0.9*score, candidates)
+			}
+		}
+	}
+
+	return matches
+}
+
+// fuzzy finds the systems spelled most like the word, and how alike they are
+func (m *Matcher) fuzzy(word string) ([]int32, float64) {
+	params := levenshtein.NewParams().BonusPrefix(3).BonusThreshold(0.3).BonusScale(0.21)
+
+	best, bestScore := make([]int32, 0), minFuzzyScore
+	for _, s := range m.names {
+		// Score will be in a range of 0 to 1, where 1 is a perfect match
+		score := levenshtein.Match(word, s.lower, params)
+		switch {
+		case score > bestScore:
+			best, bestScore = []int32{s.id}, score
+		case score == bestScore:
+			best = append(best, s.id)
+		}
+	}
+
+	return best, bestScore
+}
+
+// closeBy returns the systems that are on the map, or within a few jumps of it or of the reporter
+func (m *Matcher) closeBy(systems []int32, ctx MatchContext) []int32 {
+	close := make([]int32, 0)
+	for _, id := range systems {
+		if ctx.OnMap[id] {
+			close = append(close, id)
+			continue
+		}
+		for s := range m.galaxy.JumpsFrom(id, closeByJumps) {
+			if ctx.OnMap[s] || containsSystem(ctx.Near, s) {
+				close = append(close, id)
+				break
+			}
+		}
+	}
+	return close
+}
+
+// resolve chooses between systems that matched equally well. Systems on the map are preferred, then the systems
+// closest to the reporter. It returns the chosen system and how many were still equally likely.
+func (m *Matcher) resolve(candidates []int32, ctx MatchContext) (int32, int) {
+	if len(candidates) == 1 {
+		return candidates[0], 1
+	}
+
+	onMap := make([]int32, 0)
+	for _, id := range candidates {
+		if ctx.OnMap[id] {
+			onMap = append(onMap, id)
+		}
+	}
+	if len(onMap) > 0 {
+		candidates = onMap
+	}
+
+	if len(candidates) > 1 && len(ctx.Near) > 0 {
+		closest, closestJumps := make([]int32, 0), nearbyJumps+1
+		for _, near := range ctx.Near {
+			jumps := m.galaxy.JumpsFrom(near, nearbyJumps)
+			for _, id := range candidates {
+				d, ok := jumps[id]
+				switch {
+				case !ok || d > closestJumps:
+				case d < closestJumps:
+					closest, closestJumps = []int32{id}, d
+				case !containsSystem(closest, id):
+					closest = append(closest, id)
+				}
+			}
+		}
+		if len(closest) > 0 {
+			candidates = closest
+		}
+	}
+
+	chosen := candidates[0]
+	for _, id := range candidates[1:] {
+		if id < chosen {
+			chosen = id
+		}
+	}
+
+	return chosen, len(candidates)
+}
+
+func (t *trieNode) insert(name string, id int32) {
+	node := t
+	for _, r := range name {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		child.systems = append(child.systems, id)
+		node = child
+	}
+	node.exact = append(node.exact, id)
+}
+
+// find returns the node at the end of the given start of a name, or nil if no name starts that way
+func (t *trieNode) find(prefix string) *trieNode {
+	node := t
+	for _, r := range prefix {
+		child, ok := node.children[r]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+// normalizeName lower cases a name and drops the dashes in it, since they are often left out when typing quickly
+func normalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "")
+}
+
+// prefixAllowed is true if a word is long enough to be taken as the start of a name
+func prefixAllowed(word string) bool {
+	n := len(normalizeName(word))
+	if strings.IndexFunc(word, unicode.IsDigit) >= 0 || strings.HasSuffix(word, "-") {
+		return n >= minPrefixDigits
+	}
+	return n >= minPrefixLetters
+}
+
+// isCountWord is true of words that give a count of pilots, such as "5x", "x5", "+5", "gang of 10" or "10 neuts".
+// Counts look like the start of system names such as 5XR-KZ, X5-0EM and 10UZ-P.
+func isCountWord(tokens []Token, i int) bool {
+	w := strings.ToLower(tokens[i].Text)
+	if countWord.MatchString(w) {
+		return true
+	}
+	if _, err := strconv.Atoi(w); err != nil {
+		return false
+	}
+	return (i > 0 && strings.EqualFold(tokens[i-1].Text, "of")) ||
+		(i+1 < len(tokens) && containsString(countNouns, strings.ToLower(tokens[i+1].Text)))
+}
+
+func isStopWord(word string) bool {
+	for _, w := range stopWords {
+		if word == w {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSystem(systems []int32, id int32) bool {
+	for _, s := range systems {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
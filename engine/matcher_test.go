@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	ie := newTestEngine(t)
+	ctx := MatchContext{OnMap: map[int32]bool{30004759: true, 30004760: true, 30004761: true, 30004762: true}}
+
+	tests := []struct {
+		msg  string
+		want []int32
+	}{
+		{"1DQ1-A nv", []int32{30004759}},
+		{"1DQ1-A 5x sabre", []int32{30004759}},
+		{"x5 sabre 1DQ1-A", []int32{30004759}},
+		{"1DQ1-A gang of 10", []int32{30004759}},
+		{"10 sabres 1DQ1-A", []int32{30004759}},
+		{"1DQ1-A +5", []int32{30004759}},
+		{"49 sabre", []int32{30004760}},
+		{"MJ- +5 sabre bubble", []int32{30004761}},
+		{"5XR-KZ", []int32{30004980}},
+		{"5XR sabre", []int32{30004980}},
+		// Two characters only match systems close to the map
+		{"x5", []int32{}},
+		{"10", []int32{}},
+	}
+
+	for _, tt := range tests {
+		got := make([]int32, 0)
+		for _, m := range ie.matcher.Match(Tokenize(tt.msg), ctx) {
+			if m.Confidence >= minMatchConfidence {
+				got = append(got, m.SystemID)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
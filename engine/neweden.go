@@ -6,7 +6,6 @@ import (
 	"bytes"
 	_ "embed"
 	"encoding/json"
-	"errors"
 )
 
 type (
@@ -76,17 +75,3 @@ func (n NewEden) LoadData() (err error) {
 	return err
 }
 
-
-func (ne NewEden) GetSystem(id int32) (System, error) {
-	for _, region := range ne {
-		for _, constellation := range region.Constellations {
-			for _, system := range constellation.Systems{
-				if system.SystemID == id{
-					return system, nil
-				}
-			}
-		}
-	}
-
-	return System{}, errors.New("system not found")
-}
\ No newline at end of file
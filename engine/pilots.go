@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type (
+	// Sighting is a pilot being reported in a system
+	Sighting struct {
+		System int32     `json:"system"`
+		Time   time.Time `json:"time"`
+	}
+
+	// Hostile is a pilot that has been reported in intel, where they were last seen and where they are likely to go
+	Hostile struct {
+		Name       string    `json:"name"`
+		System     int32     `json:"system"`
+		SystemName string    `json:"systemName"`
+		LastSeen   time.Time `json:"lastSeen"`
+		// From is the system they were seen in before, which gives their direction of travel
+		From int32 `json:"from,omitempty"`
+		// Onward holds the systems next to where they are that lead further away from where they came from. Next is
+		// set when there is only one of them.
+		Onward   []int32 `json:"onward,omitempty"`
+		Next     int32   `json:"next,omitempty"`
+		NextName string  `json:"nextName,omitempty"`
+		// Sightings holds where they have been seen, oldest first
+		Sightings []Sighting `json:"sightings"`
+	}
+)
+
+const (
+	// hostileActiveFor is how long after being seen a hostile is still thought to be about
+	hostileActiveFor = 30 * time.Minute
+	// hostileForgetAfter is how long after being seen a hostile is forgotten altogether
+	hostileForgetAfter = 2 * time.Hour
+	// maxSightings is how many sightings of each hostile are kept
+	maxSightings = 20
+	// maxNameWords is the most words in a pilot name
+	maxNameWords = 3
+)
+
+var (
+	// intelWords are common in intel next to pilot names, and are never part of them
+	intelWords = []string{"spotted", "seen", "jumped", "jumping", "landed", "warped", "warping", "heading", "going",
+		"towards", "from", "via", "entered", "left", "sitting", "cloaked", "cloaky", "afk", "here", "there", "just",
+		"now", "coming", "incoming", "inc", "moving", "hostile", "hostiles", "x"}
+	// commonWords start intel often enough that on their own they are never taken as a name, as in "big fleet"
+	commonWords = []string{"big", "small", "huge", "large", "massive", "lots", "lot", "many", "few", "several", "more",
+		"possible", "possibly", "maybe", "probably", "another", "other", "same", "all", "only", "also", "still", "again",
+		"new", "lone", "solo", "single", "a", "an", "one", "some", "any", "enemy", "friendly", "blue", "blues", "reds"}
+)
+
+// ExtractPilots finds the names of pilots in an intel message. Quoted names and links to characters are always pilots.
+// Other words are taken as names only at the start of the message, when they are followed by a system, ship, count or
+// word such as "spotted", which is where names pasted from Local end up. Names pasted together are told apart by the
+// double spaces or punctuation between them.
+func ExtractPilots(msg string, tokens []Token, systems []SystemMatch, ships []ShipMatch, clearWords []string) []string {
+	names := make([]string, 0)
+	add := func(name string) {
+		name = strings.Join(strings.Fields(name), " ")
+		if name != "" && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	taken := func(tok Token) bool {
+		for _, m := range systems {
+			if tok.Start < m.End && m.Start < tok.End {
+				return true
+			}
+		}
+		for _, m := range ships {
+			if tok.Start < m.End && m.Start < tok.End {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Names at the start are only added once what follows them shows they are names
+	leading := true
+	group := make([]string, 0)
+	pending := make([]string, 0)
+	endGroup := func() {
+		single := len(group) == 1 && containsString(commonWords, strings.ToLower(group[0]))
+		if len(group) > 0 && len(group) <= maxNameWords && !single {
+			pending = append(pending, strings.Join(group, " "))
+		}
+		group = group[:0]
+	}
+
+	for i, tok := range tokens {
+		switch tok.Kind {
+		case TokenQuoted:
+			add(tok.Text)
+			endGroup()
+			continue
+		case TokenLink:
+			if isCharacterType(tok.TypeID) {
+				add(tok.Text)
+			}
+			endGroup()
+			leading = false
+			continue
+		}
+
+		if !leading {
+			continue
+		}
+		if taken(tok) || isIntelWord(tok.Text, clearWords) || strings.IndexFunc(tok.Text, unicode.IsLetter) < 0 {
+			endGroup()
+			if taken(tok) || isCount(tok.Text) || containsString(intelWords, strings.ToLower(tok.Text)) {
+				for _, name := range pending {
+					add(name)
+				}
+			}
+			leading = false
+			continue
+		}
+
+		// A double space or punctuation between words separates names
+		if i > 0 && len(group) > 0 && (tokens[i-1].Break || strings.Contains(msg[tokens[i-1].End:tok.Start], "  ")) {
+			endGroup()
+		}
+		group = append(group, tok.Text)
+	}
+	return names
+}
+
+// isCharacterType is true of the types given in showinfo links to characters
+func isCharacterType(typeID int32) bool {
+	return typeID >= 1373 && typeID <= 1386
+}
+
+// isIntelWord is true of words that say something about a report, rather than being part of a name
+func isIntelWord(word string, clearWords []string) bool {
+	w := strings.ToLower(word)
+	if isCount(w) {
+		return true
+	}
+	for _, list := range [][]string{stopWords, intelWords, countNouns, groupNouns, noVisualWords, statusWords,
+		bubbleWords, campWords, spikeWords} {
+		if containsString(list, w) {
+			return true
+		}
+	}
+	for _, cw := range clearWords {
+		if w == strings.ToLower(cw) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCount is true of a count of pilots on its own, as in "5", "+5", "5x" or "x5"
+func isCount(word string) bool {
+	_, ok := parseCount(strings.Trim(strings.ToLower(word), "+x"))
+	return ok
+}
+
+// sight records pilots being seen in systems, given in the order they were seen
+func (ie *IntelEngine) sight(names []string, systems []int32, t time.Time) {
+	if ie.hostiles == nil {
+		ie.hostiles = make(map[string]*Hostile)
+	}
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		h, ok := ie.hostiles[key]
+		if !ok {
+			h = &Hostile{Name: name}
+			ie.hostiles[key] = h
+		}
+
+		for _, sys := range systems {
+			if n := len(h.Sightings); n > 0 && h.Sightings[n-1].System == sys {
+				h.Sightings[n-1].Time = t
+				continue
+			}
+			h.Sightings = append(h.Sightings, Sighting{System: sys, Time: t})
+		}
+		if len(h.Sightings) > maxSightings {
+			h.Sightings = append([]Sighting(nil), h.Sightings[len(h.Sightings)-maxSightings:]...)
+		}
+
+		last := h.Sightings[len(h.Sightings)-1]
+		h.System = last.System
+		h.SystemName = ie.systemName(last.System)
+		h.LastSeen = last.Time
+		h.From = 0
+		if len(h.Sightings) > 1 {
+			h.From = h.Sightings[len(h.Sightings)-2].System
+		}
+		h.Onward = ie.onward(h.From, h.System)
+		h.Next, h.NextName = 0, ""
+		if len(h.Onward) == 1 {
+			h.Next = h.Onward[0]
+			h.NextName = ie.systemName(h.Next)
+		}
+	}
+
+	for key, h := range ie.hostiles {
+		if t.Sub(h.LastSeen) > hostileForgetAfter {
+			delete(ie.hostiles, key)
+		}
+	}
+}
+
+// onward returns the systems next to at that lead further away from from. The gonum graphs can't be walked safely
+// with the gonum version the module pins, so the galaxy's own jumps are used.
+func (ie *IntelEngine) onward(from, at int32) []int32 {
+	if from == 0 {
+		return nil
+	}
+
+	jumps := ie.Galaxy.JumpsFrom(from, nearbyJumps)
+	here, ok := jumps[at]
+	if !ok {
+		return nil
+	}
+
+	onward := make([]int32, 0)
+	for _, n := range ie.Galaxy.Adjacent(at) {
+		if d, ok := jumps[n]; ok && d > here {
+			onward = append(onward, n)
+		}
+	}
+	sort.Slice(onward, func(i, j int) bool {
+		return onward[i] < onward[j]
+	})
+
+	return onward
+}
+
+func (ie *IntelEngine) systemName(id int32) string {
+	s, err := ie.Galaxy.GetSystem(id)
+	if err != nil {
+		return ""
+	}
+	return s.Name
+}
+
+// Hostiles returns the pilots reported recently, with where they were last seen and where they are likely to go
+// next, the most recently seen first
+func (ie *IntelEngine) Hostiles() []Hostile {
+	now := time.Now()
+
+	hostiles := make([]Hostile, 0)
+	for _, h := range ie.Snapshot().Hostiles {
+		if now.Sub(h.LastSeen) <= hostileActiveFor {
+			hostiles = append(hostiles, h)
+		}
+	}
+	sort.Slice(hostiles, func(i, j int) bool {
+		return hostiles[i].LastSeen.After(hostiles[j].LastSeen)
+	})
+
+	return hostiles
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+func TestHostileDirection(t *testing.T) {
+	ie := newTestEngine(t)
+	if err := ie.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seen in two systems next to each other on the map, so heading on down the line
+	reps, _, _ := ie.GetFeeders()
+	now := time.Now()
+	reps <- feeds.Report{Message: "Gorski Car 1DQ1-A", Reporter: "Scout", Time: now.Add(-2 * time.Minute)}
+	reps <- feeds.Report{Message: "Gorski Car 49-U6U", Reporter: "Scout", Time: now.Add(-time.Minute)}
+
+	if err := ie.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	hostiles := ie.Hostiles()
+	if len(hostiles) != 1 {
+		t.Fatalf("got %d hostiles, want 1: %+v", len(hostiles), hostiles)
+	}
+	h := hostiles[0]
+	if h.Name != "Gorski Car" || h.System != 30004760 || h.From != 30004759 {
+		t.Errorf("got %s in %d from %d, want Gorski Car in 49-U6U from 1DQ1-A", h.Name, h.System, h.From)
+	}
+	if h.Next != 30004761 || h.NextName != "MJ-5F9" {
+		t.Errorf("got next %d %q, want MJ-5F9", h.Next, h.NextName)
+	}
+	if len(h.Sightings) != 2 {
+		t.Errorf("got %d sightings, want 2", len(h.Sightings))
+	}
+}
+
+func TestExtractPilots(t *testing.T) {
+	ie := newTestEngine(t)
+	ctx := MatchContext{OnMap: map[int32]bool{30004759: true, 30004760: true, 30004761: true, 30004762: true}}
+
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"Gorski Car 1DQ1-A", []string{"Gorski Car"}},
+		{"Gorski Car  Other Guy 1DQ1-A +2", []string{"Gorski Car", "Other Guy"}},
+		{"Gorski Car, Other Guy 1DQ1-A", []string{"Gorski Car", "Other Guy"}},
+		{"Gorski Car sabre 1DQ1-A", []string{"Gorski Car"}},
+		{"Gorski Car +5 1DQ1-A", []string{"Gorski Car"}},
+		{"Gorski Car spotted 1DQ1-A", []string{"Gorski Car"}},
+		{`1DQ1-A "Some Pilot" +1`, []string{"Some Pilot"}},
+		{"<url=showinfo:1377//90000001>Some Pilot</url> 1DQ1-A", []string{"Some Pilot"}},
+		// Words that start intel rather than names
+		{"Big fleet 1DQ1-A", []string{}},
+		{"big 1DQ1-A", []string{}},
+		{"Lots of reds 49-U6U", []string{}},
+		{"Some sabres MJ-5F9", []string{}},
+		{"1DQ1-A Gorski Car", []string{}},
+		{"Gorski Car", []string{}},
+	}
+
+	for _, tt := range tests {
+		tokens := Tokenize(tt.msg)
+		var systems []SystemMatch
+		for _, m := range ie.matcher.Match(tokens, ctx) {
+			if m.Confidence >= minMatchConfidence {
+				systems = append(systems, m)
+			}
+		}
+
+		got := ExtractPilots(tt.msg, tokens, systems, ie.ships.Match(tokens), nil)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractPilots(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
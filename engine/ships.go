@@ -0,0 +1,199 @@
+package engine
+
+//go:generate go run gen_shipdata.go
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"strings"
+)
+
+type (
+	// ThreatClass is the kind of threat a ship poses, such as tackle or a capital
+	ThreatClass string
+
+	ShipData struct {
+		Groups    map[int32]ShipGroup     `json:"groups"`
+		Types     map[int32]ShipType      `json:"types"`
+		Nicknames map[string]ShipNickname `json:"nicknames"`
+	}
+
+	ShipGroup struct {
+		GroupID int32       `json:"group_id"`
+		Name    string      `json:"name"`
+		Class   ThreatClass `json:"class"`
+	}
+
+	ShipType struct {
+		GroupID int32  `json:"group_id"`
+		Name    string `json:"name"`
+		TypeID  int32  `json:"type_id"`
+	}
+
+	// ShipNickname is a name pilots use for a ship, or for a whole group of ships
+	ShipNickname struct {
+		GroupID int32 `json:"group_id,omitempty"`
+		TypeID  int32 `json:"type_id,omitempty"`
+	}
+
+	// Ship is a ship recognised in intel. TypeID is 0 when only the group of ship is known, as in "dread".
+	Ship struct {
+		TypeID  int32       `json:"typeId,omitempty"`
+		GroupID int32       `json:"groupId"`
+		Name    string      `json:"name"`
+		Group   string      `json:"group"`
+		Class   ThreatClass `json:"class"`
+	}
+
+	// ShipMatch is a ship found in an intel message, Start and End are its byte offsets in the message
+	ShipMatch struct {
+		Ship
+		Text  string `json:"text"`
+		Start int    `json:"start"`
+		End   int    `json:"end"`
+	}
+
+	// Ships finds ships in tokenized intel messages, by their names, the names of their groups and their nicknames
+	Ships struct {
+		names map[string]Ship
+		// words is the most words in any name
+		words int
+	}
+)
+
+const (
+	ThreatPod     ThreatClass = "pod"
+	ThreatMining  ThreatClass = "mining"
+	ThreatHauler  ThreatClass = "hauler"
+	ThreatCombat  ThreatClass = "combat"
+	ThreatCovert  ThreatClass = "covert"
+	ThreatTackle  ThreatClass = "tackle"
+	ThreatBomber  ThreatClass = "bomber"
+	ThreatCapital ThreatClass = "capital"
+)
+
+var (
+	//go:embed ships.json
+	shipdata []byte
+
+	// threatOrder ranks the threat classes from least to most dangerous
+	threatOrder = []ThreatClass{ThreatPod, ThreatMining, ThreatHauler, ThreatCombat, ThreatCovert, ThreatTackle,
+		ThreatBomber, ThreatCapital}
+
+	// shipStopWords are ship names that are more often just words in intel
+	shipStopWords = []string{"probe", "probes", "burst", "venture", "prospect", "vigil", "impel", "crane"}
+)
+
+func (sd *ShipData) LoadData() (err error) {
+	raw := bytes.NewReader(shipdata)
+	jdata := json.NewDecoder(raw)
+	err = jdata.Decode(sd)
+	return err
+}
+
+// LoadShips loads the embedded ship data and indexes it
+func LoadShips() (*Ships, error) {
+	var sd ShipData
+	err := sd.LoadData()
+	if err != nil {
+		return nil, err
+	}
+	return NewShips(sd), nil
+}
+
+// NewShips indexes ships by name. The names of single ships win over nicknames, which win over the names of groups.
+func NewShips(sd ShipData) *Ships {
+	s := &Ships{
+		names: make(map[string]Ship),
+	}
+
+	add := func(name string, ship Ship) {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if _, ok := s.names[name]; ok || name == "" || containsString(shipStopWords, name) {
+			return
+		}
+		s.names[name] = ship
+		if n := len(strings.Fields(name)); n > s.words {
+			s.words = n
+		}
+	}
+
+	typeShip := func(t ShipType) Ship {
+		g := sd.Groups[t.GroupID]
+		return Ship{TypeID: t.TypeID, GroupID: t.GroupID, Name: t.Name, Group: g.Name, Class: g.Class}
+	}
+	groupShip := func(g ShipGroup) Ship {
+		return Ship{GroupID: g.GroupID, Name: g.Name, Group: g.Name, Class: g.Class}
+	}
+
+	for _, t := range sd.Types {
+		add(t.Name, typeShip(t))
+	}
+	for nick, n := range sd.Nicknames {
+		if t, ok := sd.Types[n.TypeID]; ok {
+			add(nick, typeShip(t))
+		} else if g, ok := sd.Groups[n.GroupID]; ok {
+			add(nick, groupShip(g))
+		}
+	}
+	for _, g := range sd.Groups {
+		add(g.Name, groupShip(g))
+	}
+
+	return s
+}
+
+// Match finds every ship named in the tokens of a message. The longest name wins, and plurals such as "sabres" are
+// matched too.
+func (s *Ships) Match(tokens []Token) []ShipMatch {
+	matches := make([]ShipMatch, 0)
+
+tokens:
+	for i := 0; i < len(tokens); i++ {
+		for n := s.words; n > 0; n-- {
+			phrase, ok := Phrase(tokens, i, n)
+			if !ok {
+				continue
+			}
+			ship, ok := s.lookup(strings.ToLower(phrase))
+			if !ok {
+				continue
+			}
+			matches = append(matches, ShipMatch{
+				Ship:  ship,
+				Text:  phrase,
+				Start: tokens[i].Start,
+				End:   tokens[i+n-1].End,
+			})
+			i += n - 1
+			continue tokens
+		}
+	}
+
+	return matches
+}
+
+func (s *Ships) lookup(name string) (Ship, bool) {
+	if ship, ok := s.names[name]; ok {
+		return ship, true
+	}
+	if strings.HasSuffix(name, "s") {
+		ship, ok := s.names[strings.TrimSuffix(name, "s")]
+		return ship, ok
+	}
+	return Ship{}, false
+}
+
+// WorstThreat returns the most dangerous class of ship in a list, or an empty class for no ships
+func WorstThreat(ships []Ship) ThreatClass {
+	worst, rank := ThreatClass(""), -1
+	for _, ship := range ships {
+		for r, c := range threatOrder {
+			if c == ship.Class && r > rank {
+				worst, rank = c, r
+			}
+		}
+	}
+	return worst
+}
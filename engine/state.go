@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/eve-spyglass/spyglass2/feeds"
+)
+
+type (
+	// savedState is the intel state written to disk on exit, so the map isn't blank when the app is next opened
+	savedState struct {
+		Saved       time.Time           `json:"saved"`
+		Status      map[int32]uint8     `json:"status"`
+		LastUpdated map[int32]time.Time `json:"lastUpdated"`
+		History     []feeds.Report      `json:"history"`
+	}
+)
+
+const (
+	// maxSavedReports is how many of the latest reports are kept between runs
+	maxSavedReports = 500
+)
+
+// SaveState writes the latest snapshot of the intel state to a file
+func (ie *IntelEngine) SaveState(path string) error {
+	snap := ie.Snapshot()
+
+	history := snap.History
+	if len(history) > maxSavedReports {
+		history = history[len(history)-maxSavedReports:]
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	return enc.Encode(savedState{
+		Saved:       snap.Taken,
+		Status:      snap.Status,
+		LastUpdated: snap.LastUpdated,
+		History:     history,
+	})
+}
+
+// LoadState restores the intel state written by SaveState. A missing file is not an error, there is just nothing
+// to restore.
+func (ie *IntelEngine) LoadState(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var st savedState
+	err = json.NewDecoder(f).Decode(&st)
+	if err != nil {
+		return err
+	}
+
+	return ie.do(func() {
+		for k, v := range st.Status {
+			ie.currentStatus[k] = v
+		}
+		for k, v := range st.LastUpdated {
+			ie.lastUpdated[k] = v
+		}
+		if ie.recentReports == nil {
+			ie.recentReports = make(map[string]*feeds.Report)
+		}
+		history := make([]*feeds.Report, 0, len(st.History)+len(ie.reportHistory))
+		for i := range st.History {
+			rep := &st.History[i]
+			history = append(history, rep)
+			// The backfill may send the latest reports again, they are merged into these rather than repeated
+			if r, ok := ie.recentReports[rep.Hash()]; !ok || rep.Time.After(r.Time) {
+				ie.recentReports[rep.Hash()] = rep
+			}
+		}
+		ie.reportHistory = append(history, ie.reportHistory...)
+		ie.trimHistory()
+	})
+}
@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	TokenKind uint8
+
+	// Token is a span of an intel message. Start and End are byte offsets into the message, so the original text of
+	// the token is always message[Start:End].
+	Token struct {
+		Text  string    `json:"text"`
+		Kind  TokenKind `json:"kind"`
+		Start int       `json:"start"`
+		End   int       `json:"end"`
+
+		// TypeID and ItemID are set for links that say what they link to, such as showinfo:5//30004759 for a system
+		TypeID int32 `json:"typeId,omitempty"`
+		ItemID int32 `json:"itemId,omitempty"`
+
+		// Break is set when the token is followed by punctuation that ends a phrase, such as a comma, so that names of
+		// more than one word are not matched across it
+		Break bool `json:"break,omitempty"`
+	}
+)
+
+const (
+	// TokenWord is a single word with any punctuation around it removed
+	TokenWord TokenKind = iota
+	// TokenQuoted is text in double quotes, which is how pilot names with spaces are given
+	TokenQuoted
+	// TokenLink is the text of a link pasted into chat, which is the name of whatever it links to
+	TokenLink
+)
+
+const (
+	// solarSystemTypeID is the type given in showinfo links to solar systems
+	solarSystemTypeID = 5
+)
+
+var (
+	// linkMarkup matches the markup of links pasted into chat, as found in some logs
+	linkMarkup = regexp.MustCompile(`(?i)^<(?:url=|a\s+href=)["']?([^"'>]*)["']?>(.*?)</(?:url|a)>`)
+	// showInfo picks the type and item out of a showinfo link
+	showInfo = regexp.MustCompile(`(?i)^showinfo:(\d+)(?://(\d+))?`)
+	// anyMarkup matches other tags, such as colours and fonts, which are ignored
+	anyMarkup = regexp.MustCompile(`^<[^<>]*>`)
+)
+
+// Tokenize splits an intel message into words, quoted names and links. Punctuation is removed from around words,
+// but kept inside them so that names like 1DQ1-A stay whole. Separators such as commas and slashes mark the end of a
+// phrase.
+func Tokenize(msg string) []Token {
+	tokens := make([]Token, 0)
+
+	// breakLast ends the phrase at the last token, if there is one
+	breakLast := func() {
+		if len(tokens) > 0 {
+			tokens[len(tokens)-1].Break = true
+		}
+	}
+
+	i := 0
+	for i < len(msg) {
+		r, size := utf8.DecodeRuneInString(msg[i:])
+
+		switch {
+		case r == '<':
+			if m := linkMarkup.FindStringSubmatchIndex(msg[i:]); m != nil {
+				tok := Token{
+					Text:  strings.TrimSpace(stripMarkup(msg[i+m[4] : i+m[5]])),
+					Kind:  TokenLink,
+					Start: i,
+					End:   i + m[1],
+				}
+				if si := showInfo.FindStringSubmatch(msg[i+m[2] : i+m[3]]); si != nil {
+					tok.TypeID = parseID(si[1])
+					tok.ItemID = parseID(si[2])
+				}
+				if tok.Text != "" {
+					tokens = append(tokens, tok)
+				}
+				i += m[1]
+				continue
+			}
+			if m := anyMarkup.FindStringIndex(msg[i:]); m != nil {
+				i += m[1]
+				continue
+			}
+			breakLast()
+			i += size
+
+		case isQuote(r):
+			end := closingQuote(msg, i+size)
+			if end < 0 {
+				// An unmatched quote is just punctuation
+				i += size
+				continue
+			}
+			_, closeSize := utf8.DecodeRuneInString(msg[end:])
+			text := strings.TrimSpace(msg[i+size : end])
+			if text != "" {
+				tokens = append(tokens, Token{
+					Text:  text,
+					Kind:  TokenQuoted,
+					Start: i,
+					End:   end + closeSize,
+				})
+			}
+			i = end + closeSize
+
+		case unicode.IsSpace(r):
+			i += size
+
+		case isSeparator(r):
+			breakLast()
+			i += size
+
+		default:
+			start := i
+			for i < len(msg) {
+				r, size := utf8.DecodeRuneInString(msg[i:])
+				if unicode.IsSpace(r) || isSeparator(r) || isQuote(r) || r == '<' {
+					break
+				}
+				i += size
+			}
+
+			word, trimmed, ends := trimWord(msg[start:i])
+			if word == "" {
+				breakLast()
+				continue
+			}
+			tokens = append(tokens, Token{
+				Text:  word,
+				Kind:  TokenWord,
+				Start: start + trimmed,
+				End:   start + trimmed + len(word),
+				Break: ends,
+			})
+		}
+	}
+
+	return tokens
+}
+
+// Phrase joins n tokens starting at i with single spaces, for matching names of more than one word. It fails if
+// there are not enough tokens, if any of them is not a plain word, or if a phrase ends before the last of them.
+func Phrase(tokens []Token, i, n int) (string, bool) {
+	if n < 1 || i < 0 || i+n > len(tokens) {
+		return "", false
+	}
+
+	words := make([]string, n)
+	for j := 0; j < n; j++ {
+		tok := tokens[i+j]
+		if tok.Kind != TokenWord || (tok.Break && j < n-1) {
+			return "", false
+		}
+		words[j] = tok.Text
+	}
+
+	return strings.Join(words, " "), true
+}
+
+// trimWord removes punctuation from either end of a word. It returns the word, how many bytes were removed from the
+// front, and whether the punctuation removed from the end finishes a phrase. A word of nothing but punctuation comes
+// back empty.
+func trimWord(raw string) (word string, trimmed int, ends bool) {
+	word = strings.TrimLeftFunc(raw, isTrimmable)
+	trimmed = len(raw) - len(word)
+
+	trimmedWord := strings.TrimRightFunc(word, isTrimmable)
+	ends = strings.ContainsAny(word[len(trimmedWord):], ".:!?;")
+	if strings.IndexFunc(trimmedWord, isAlphanumeric) < 0 {
+		// Nothing but punctuation, such as a dash between two names, which always ends the phrase
+		return "", len(raw), true
+	}
+
+	return trimmedWord, trimmed, ends
+}
+
+// isTrimmable is true of the punctuation that is removed from around words. Plus and minus signs are kept in front
+// of numbers, as in "jita +5".
+func isTrimmable(r rune) bool {
+	return !isAlphanumeric(r) && r != '+' && r != '-'
+}
+
+func isAlphanumeric(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isSeparator is true of punctuation that splits words and ends a phrase wherever it is
+func isSeparator(r rune) bool {
+	switch r {
+	case ',', ';', '/', '\\', '|', '(', ')', '[', ']', '{', '}', '!', '?', '>':
+		return true
+	}
+	return false
+}
+
+func isQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”' || r == '«' || r == '»'
+}
+
+// closingQuote finds the quote that closes one opened just before from, or -1 if there isn't one
+func closingQuote(msg string, from int) int {
+	for i := from; i < len(msg); {
+		r, size := utf8.DecodeRuneInString(msg[i:])
+		if isQuote(r) {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+func stripMarkup(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		if m := anyMarkup.FindStringIndex(s); m != nil {
+			s = s[m[1]:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		b.WriteRune(r)
+		s = s[size:]
+	}
+	return b.String()
+}
+
+func parseID(s string) int32 {
+	id, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(id)
+}
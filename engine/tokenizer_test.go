@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tok is the part of a Token the tests compare, the offsets are checked against the message instead
+type tok struct {
+	Text   string
+	Kind   TokenKind
+	Break  bool
+	TypeID int32
+	ItemID int32
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want []tok
+	}{
+		{"", []tok{}},
+		{"1DQ1-A,", []tok{{Text: "1DQ1-A", Break: true}}},
+		{"MJ-5F9?", []tok{{Text: "MJ-5F9", Break: true}}},
+		{"Old Man Star +5", []tok{{Text: "Old"}, {Text: "Man"}, {Text: "Star"}, {Text: "+5"}}},
+		{"Old Man, Star", []tok{{Text: "Old"}, {Text: "Man", Break: true}, {Text: "Star"}}},
+		{"49-U6U / MJ-5F9 nv", []tok{{Text: "49-U6U", Break: true}, {Text: "MJ-5F9"}, {Text: "nv"}}},
+		{"1DQ1-A - 49-U6U", []tok{{Text: "1DQ1-A", Break: true}, {Text: "49-U6U"}}},
+		{"1DQ1-A clr.", []tok{{Text: "1DQ1-A"}, {Text: "clr", Break: true}}},
+		{"(sabre) in 1DQ1-A!", []tok{{Text: "sabre", Break: true}, {Text: "in"}, {Text: "1DQ1-A", Break: true}}},
+		{`"Some Pilot" 1DQ1-A`, []tok{{Text: "Some Pilot", Kind: TokenQuoted}, {Text: "1DQ1-A"}}},
+		{"“Some Pilot”  “Other Pilot” +2", []tok{
+			{Text: "Some Pilot", Kind: TokenQuoted}, {Text: "Other Pilot", Kind: TokenQuoted}, {Text: "+2"},
+		}},
+		{`unmatched "quote`, []tok{{Text: "unmatched"}, {Text: "quote"}}},
+		{"<url=showinfo:5//30004759>1DQ1-A</url> +5", []tok{
+			{Text: "1DQ1-A", Kind: TokenLink, TypeID: 5, ItemID: 30004759}, {Text: "+5"},
+		}},
+		{"<url=showinfo:1375//90000001>Some Pilot</url> in 49-U6U", []tok{
+			{Text: "Some Pilot", Kind: TokenLink, TypeID: 1375, ItemID: 90000001}, {Text: "in"}, {Text: "49-U6U"},
+		}},
+		{`<a href="showinfo:5//30004759">1DQ1-A</a>`, []tok{
+			{Text: "1DQ1-A", Kind: TokenLink, TypeID: 5, ItemID: 30004759},
+		}},
+		{`<font size="12"><b>1DQ1-A</b></font> nv`, []tok{{Text: "1DQ1-A"}, {Text: "nv"}}},
+	}
+
+	for _, tt := range tests {
+		tokens := Tokenize(tt.msg)
+
+		got := make([]tok, len(tokens))
+		for i, token := range tokens {
+			got[i] = tok{Text: token.Text, Kind: token.Kind, Break: token.Break, TypeID: token.TypeID, ItemID: token.ItemID}
+
+			if token.Start < 0 || token.End > len(tt.msg) || token.Start >= token.End {
+				t.Errorf("Tokenize(%q) token %d has offsets %d:%d", tt.msg, i, token.Start, token.End)
+			} else if token.Kind == TokenWord && tt.msg[token.Start:token.End] != token.Text {
+				t.Errorf("Tokenize(%q) token %d is %q but covers %q", tt.msg, i, token.Text, tt.msg[token.Start:token.End])
+			}
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q)\n got %+v\nwant %+v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestPhrase(t *testing.T) {
+	tests := []struct {
+		msg  string
+		i, n int
+		want string
+		ok   bool
+	}{
+		{"Old Man Star +5", 0, 3, "Old Man Star", true},
+		{"Old Man Star +5", 1, 2, "Man Star", true},
+		{"Old Man Star +5", 2, 3, "", false},
+		{"Old Man, Star", 0, 3, "", false},
+		{"Old Man, Star", 0, 2, "Old Man", true},
+		{`"Old Man" Star`, 0, 2, "", false},
+		{"Old Man Star", 0, 0, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Phrase(Tokenize(tt.msg), tt.i, tt.n)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Phrase(%q, %d, %d) = %q, %v, want %q, %v", tt.msg, tt.i, tt.n, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package feeds
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// logFile is a log found in one of several log directories
+type logFile struct {
+	os.FileInfo
+	path string
+}
+
+// SetBackfill sets how far back intel and location reports are read from existing chat logs when the feed starts.
+// A window of zero disables the backfill.
+func (f *LogFeed) SetBackfill(window time.Duration) {
+	f.mu.Lock()
+	f.backfill = window
+	f.mu.Unlock()
+
+	f.notify()
+}
+
+// backfillLogs reads the chat logs that were already written when the feed started, or that the watch has not
+// looked at yet. Everything is read so that the sessions pick up from the end of each file, but only reports inside
+// the backfill window are kept. The last location of each character is always kept, however long ago it was, as
+// it is where they still are.
+func (cw *chatWatch) backfillLogs(now time.Time) (reps []Report, locs []Locstat) {
+	// The logs from every directory are read together, oldest first, so that locations are reported in order
+	var files []logFile
+	for dir := range cw.dirs.attached {
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Printf("LW: backfill failed to list logs: %s", err)
+			continue
+		}
+		for _, fi := range fis {
+			files = append(files, logFile{path: filepath.Join(dir, fi.Name()), FileInfo: fi})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	cutoff := now.Add(-cw.backfill)
+	latest := make(map[string]Locstat)
+	for _, fi := range files {
+		if fi.IsDir() || now.Sub(fi.ModTime()) > sessionStaleAfter {
+			continue
+		}
+		if _, ok := cw.sessions[fi.path]; ok {
+			// Already being followed, the watcher will pick up anything new
+			continue
+		}
+
+		rs, ls := cw.checkLogFile(fi.path)
+		for _, l := range ls {
+			if l.Time.After(latest[l.Character].Time) {
+				latest[l.Character] = l
+			}
+		}
+		if cw.backfill <= 0 || fi.ModTime().Before(cutoff) {
+			continue
+		}
+
+		for _, r := range rs {
+			if !r.Time.Before(cutoff) {
+				reps = append(reps, r)
+			}
+		}
+		for _, l := range ls {
+			if !l.Time.Before(cutoff) {
+				locs = append(locs, l)
+			}
+		}
+	}
+
+	for _, l := range latest {
+		// Those inside the window have been kept already
+		if cw.backfill <= 0 || l.Time.Before(cutoff) {
+			locs = append(locs, l)
+		}
+	}
+
+	sort.Slice(reps, func(i, j int) bool {
+		return reps[i].Time.Before(reps[j].Time)
+	})
+	sort.Slice(locs, func(i, j int) bool {
+		return locs[i].Time.Before(locs[j].Time)
+	})
+
+	log.Printf("LW: backfilled %d intel reports and %d location reports", len(reps), len(locs))
+
+	return reps, locs
+}
@@ -0,0 +1,147 @@
+package feeds
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// ChannelInfo describes a chat channel found in the chat logs
+	ChannelInfo struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		// Listeners are the characters that have logged the channel
+		Listeners []string `json:"listeners"`
+		// LastActivity is when the channel was last written to by any of the listeners
+		LastActivity time.Time `json:"lastActivity"`
+		// MessagesPerHour is the average message rate over the last day
+		MessagesPerHour float64 `json:"messagesPerHour"`
+	}
+)
+
+const (
+	// channelRateWindow is how far back messages are counted for the message rate
+	channelRateWindow = 24 * time.Hour
+)
+
+// ListChannels reads the headers of every chat log in the log directories and lists the channels found, most
+// recently active first. Logs written in the last day are read in full to work out how busy the channel is.
+func (f *LogFeed) ListChannels() ([]ChannelInfo, error) {
+	dirs, _, _ := f.settings()
+	now := time.Now()
+
+	channels := make(map[string]*ChannelInfo)
+	// Several listeners hear the same messages, so they are only counted once for the rate
+	messages := make(map[string]map[ChatLine]bool)
+	var lastErr error
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Printf("LW: failed to list channels in %s: %s", dir, err)
+			lastErr = err
+			continue
+		}
+
+		for _, fi := range files {
+			if fi.IsDir() {
+				continue
+			}
+
+			h, recent, err := readChannelLog(filepath.Join(dir, fi.Name()), now.Add(-channelRateWindow))
+			if err != nil {
+				log.Printf("DEBUG: LW: skipping %s: %s", fi.Name(), err)
+				continue
+			}
+
+			ci, ok := channels[h.ChannelID]
+			if !ok {
+				ci = &ChannelInfo{ID: h.ChannelID}
+				channels[h.ChannelID] = ci
+				messages[h.ChannelID] = make(map[ChatLine]bool)
+			}
+			if fi.ModTime().After(ci.LastActivity) {
+				// Channels can be renamed, so use the latest name
+				ci.Name = h.ChannelName
+				ci.LastActivity = fi.ModTime()
+			}
+			if !containsString(ci.Listeners, h.Listener) {
+				ci.Listeners = append(ci.Listeners, h.Listener)
+			}
+			for _, cl := range recent {
+				messages[h.ChannelID][cl] = true
+			}
+		}
+	}
+
+	if len(channels) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	list := make([]ChannelInfo, 0, len(channels))
+	for id, ci := range channels {
+		ci.MessagesPerHour = float64(len(messages[id])) / channelRateWindow.Hours()
+		sort.Strings(ci.Listeners)
+		list = append(list, *ci)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastActivity.After(list[j].LastActivity)
+	})
+
+	return list, nil
+}
+
+// readChannelLog reads the header of a chat log along with the messages sent since the given time. Logs that have
+// not been written to since then are not read past the header.
+func readChannelLog(path string, since time.Time) (h ChatlogHeader, recent []ChatLine, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ChatlogHeader{}, nil, err
+	}
+	if fi.ModTime().Before(since) {
+		fh, err := os.Open(path)
+		if err != nil {
+			return ChatlogHeader{}, nil, err
+		}
+		defer fh.Close()
+		h, err = ParseChatlogHeader(fh)
+		return h, nil, err
+	}
+
+	lines, _, err := newLogTail(path).Lines()
+	if err != nil {
+		return ChatlogHeader{}, nil, err
+	}
+
+	var p headerParser
+	for _, text := range lines {
+		consumed, err := p.Add(text)
+		if err != nil {
+			return ChatlogHeader{}, nil, err
+		}
+		if consumed {
+			continue
+		}
+		if cl, err := ParseChatLine(text); err == nil && !cl.Time.Before(since) {
+			recent = append(recent, cl)
+		}
+	}
+	if !p.Ready() {
+		return ChatlogHeader{}, nil, &ChatlogHeaderError{Line: p.line, Err: HeaderTruncated}
+	}
+
+	return p.Header(), recent, nil
+}
+
+// matchesChannel reports whether a configured room refers to the channel, either by its ID or by its name in any case
+func matchesChannel(room string, h ChatlogHeader) bool {
+	room = strings.TrimSpace(room)
+	if room == "" {
+		return false
+	}
+	return room == h.ChannelID || strings.EqualFold(room, h.ChannelName)
+}
@@ -0,0 +1,82 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// ChatLine is a single message from a chat log, in the form "[ 2006.01.02 15:04:05 ] Sender > Message"
+	ChatLine struct {
+		Time    time.Time
+		Sender  string
+		Message string
+	}
+
+	// ChatLineError describes why a line from a chat log could not be parsed
+	ChatLineError struct {
+		Text string
+		Err  error
+	}
+)
+
+var (
+	LineNotMessage   = errors.New("chat line is not a message")
+	LineBadTimestamp = errors.New("chat line has an invalid timestamp")
+	LineNoSender     = errors.New("chat line has no sender")
+)
+
+func (e *ChatLineError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Err, e.Text)
+}
+
+func (e *ChatLineError) Unwrap() error {
+	return e.Err
+}
+
+// ParseChatLine splits a line from a chat log into its parts. It never panics, lines that are not messages return a
+// *ChatLineError wrapping one of LineNotMessage, LineBadTimestamp or LineNoSender.
+func ParseChatLine(text string) (ChatLine, error) {
+	text = cleanLine(text)
+
+	t, rest, err := splitTimestamp(text)
+	if err != nil {
+		return ChatLine{}, err
+	}
+
+	// Pilot names cannot contain a '>' so the first one ends the sender, any others belong to the message
+	sep := strings.Index(rest, ">")
+	if sep < 0 {
+		return ChatLine{}, &ChatLineError{Text: text, Err: LineNoSender}
+	}
+	sender := strings.TrimSpace(rest[:sep])
+	if sender == "" {
+		return ChatLine{}, &ChatLineError{Text: text, Err: LineNoSender}
+	}
+
+	return ChatLine{
+		Time:    t,
+		Sender:  sender,
+		Message: strings.TrimSpace(rest[sep+1:]),
+	}, nil
+}
+
+// splitTimestamp parses the "[ 2006.01.02 15:04:05 ]" prefix shared by chat and game log lines
+func splitTimestamp(text string) (t time.Time, rest string, err error) {
+	if !strings.HasPrefix(text, "[") {
+		return time.Time{}, "", &ChatLineError{Text: text, Err: LineNotMessage}
+	}
+	end := strings.Index(text, "]")
+	if end < 0 {
+		return time.Time{}, "", &ChatLineError{Text: text, Err: LineNotMessage}
+	}
+
+	t, err = time.Parse(logTimeFormat, strings.TrimSpace(text[1:end]))
+	if err != nil {
+		return time.Time{}, "", &ChatLineError{Text: text, Err: LineBadTimestamp}
+	}
+
+	return t, text[end+1:], nil
+}
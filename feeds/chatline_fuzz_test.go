@@ -0,0 +1,62 @@
+//go:build go1.18
+// +build go1.18
+
+package feeds
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func FuzzParseChatLine(f *testing.F) {
+	// Odd lines found in real chat logs
+	seeds := []string{
+		"",
+		"\ufeff",
+		"[ 2021.03.04 05:06:07 ]",
+		"[ 2021.03.04 05:06:07 ] ",
+		"[ 2021.03.04 05:06:07 ] Some Pilot",
+		"[ 2021.03.04 05:06:07 ] > 1DQ1-A clr",
+		"[ 2021.03.04 05:06:07 ] Some Pilot > 1DQ1-A > gate > +5",
+		"[ 2021.03.04 05:06:07 ] Some Pilot >> 49-U6U",
+		"\ufeff[ 2021.03.04 05:06:07 ] Some Pilot > MJ-5F9 nv",
+		"\ufeff[ 2021.03.04 05:06:07 ] EVE System > Channel MOTD: Report hostiles here > and nothing else",
+		"[ 2021.03.04 05:06:07 ] EVE System > Channel changed to Local : 1DQ1-A",
+		"[ 2021.13.45 25:61:61 ] Some Pilot > bad time",
+		"[ 2021.03.04 05:06:07 Some Pilot > no closing bracket",
+		"Some Pilot > no timestamp",
+		" continued message from the line before",
+		"[ 2021.03.04 05:06:07 ]   Some Pilot   >   Old Man Star  \r",
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, text string) {
+		cl, err := ParseChatLine(text)
+		if err != nil {
+			var cle *ChatLineError
+			if !errors.As(err, &cle) {
+				t.Fatalf("ParseChatLine(%q) returned %T, want *ChatLineError", text, err)
+			}
+			if !errors.Is(err, LineNotMessage) && !errors.Is(err, LineBadTimestamp) && !errors.Is(err, LineNoSender) {
+				t.Fatalf("ParseChatLine(%q) returned unexpected error %s", text, err)
+			}
+			if cl != (ChatLine{}) {
+				t.Fatalf("ParseChatLine(%q) returned %+v along with an error", text, cl)
+			}
+			return
+		}
+
+		if cl.Time.IsZero() {
+			t.Errorf("ParseChatLine(%q) has no time", text)
+		}
+		if cl.Sender == "" || strings.Contains(cl.Sender, ">") {
+			t.Errorf("ParseChatLine(%q) has sender %q", text, cl.Sender)
+		}
+		if cl.Sender != strings.TrimSpace(cl.Sender) || cl.Message != strings.TrimSpace(cl.Message) {
+			t.Errorf("ParseChatLine(%q) is not trimmed: %+v", text, cl)
+		}
+	})
+}
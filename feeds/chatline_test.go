@@ -0,0 +1,45 @@
+package feeds
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseChatLine(t *testing.T) {
+	at := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		text string
+		want ChatLine
+		err  error
+	}{
+		{"[ 2021.03.04 05:06:07 ] Some Pilot > 1DQ1-A clr", ChatLine{at, "Some Pilot", "1DQ1-A clr"}, nil},
+		// Only the first '>' ends the sender, the rest are part of the message
+		{"[ 2021.03.04 05:06:07 ] Some Pilot > 1DQ1-A > gate > +5", ChatLine{at, "Some Pilot", "1DQ1-A > gate > +5"}, nil},
+		{"[ 2021.03.04 05:06:07 ] Some Pilot > 49-U6U>MJ-5F9", ChatLine{at, "Some Pilot", "49-U6U>MJ-5F9"}, nil},
+		{"[ 2021.03.04 05:06:07 ] Some Pilot >> 49-U6U", ChatLine{at, "Some Pilot", "> 49-U6U"}, nil},
+		{"[ 2021.03.04 05:06:07 ] Some Pilot > ", ChatLine{at, "Some Pilot", ""}, nil},
+		{
+			"\ufeff[ 2021.03.04 05:06:07 ] EVE System > Channel MOTD: Report hostiles here > and nothing else",
+			ChatLine{at, "EVE System", "Channel MOTD: Report hostiles here > and nothing else"}, nil,
+		},
+		{"[ 2021.03.04 05:06:07 ]   Some Pilot   >   Old Man Star  \r", ChatLine{at, "Some Pilot", "Old Man Star"}, nil},
+		{"[ 2021.03.04 05:06:07 ] > 1DQ1-A clr", ChatLine{}, LineNoSender},
+		{"[ 2021.03.04 05:06:07 ] Some Pilot", ChatLine{}, LineNoSender},
+		{"[ 2021.13.45 25:61:61 ] Some Pilot > bad time", ChatLine{}, LineBadTimestamp},
+		{"Some Pilot > no timestamp", ChatLine{}, LineNotMessage},
+		{" continued message from the line before", ChatLine{}, LineNotMessage},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseChatLine(tt.text)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseChatLine(%q) returned error %v, want %v", tt.text, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseChatLine(%q)\n got %+v\nwant %+v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -3,17 +3,51 @@ package feeds
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"time"
 )
 
 type (
 	Report struct {
-		Message  string    `json:"message"`
-		Reporter string    `json:"reporter"`
-		Listener string    `json:"listener"`
-		Source   string    `json:"source"`
-		Time     time.Time `json:"time"`
-		Status   uint8     `json:"status"`
+		Message  string `json:"message"`
+		Reporter string `json:"reporter"`
+		Listener string `json:"listener"`
+		Source   string `json:"source"`
+		// System is set when the feed already knows which system the report is about
+		System string `json:"system,omitempty"`
+		// Attacker is set when the feed saw the pilot attack the listener, so the report is about wherever the
+		// listener is
+		Attacker string `json:"attacker,omitempty"`
+		// Ship is what the attacker was flying, when the feed saw it
+		Ship   string    `json:"ship,omitempty"`
+		Time   time.Time `json:"time"`
+		Status uint8     `json:"status"`
+
+		// Listeners and Sources hold every listener and source that saw the report, once copies have been merged
+		Listeners []string `json:"listeners,omitempty"`
+		Sources   []string `json:"sources,omitempty"`
+
+		// Intel is what the engine made of the message. It is set once the report has been checked and is not
+		// changed after that.
+		Intel *Intel `json:"intel,omitempty"`
+	}
+
+	// Intel is the structured meaning of an intel report
+	Intel struct {
+		Systems []int32 `json:"systems,omitempty"`
+		// Pilots is the number of hostiles reported, or 0 if the report didn't say
+		Pilots     int      `json:"pilots,omitempty"`
+		PilotNames []string `json:"pilotNames,omitempty"`
+		Ships      []string `json:"ships,omitempty"`
+		// Threat is the most dangerous class of the ships, such as tackle or capital
+		Threat string `json:"threat,omitempty"`
+
+		Clear         bool `json:"clear,omitempty"`
+		NoVisual      bool `json:"noVisual,omitempty"`
+		StatusRequest bool `json:"statusRequest,omitempty"`
+		Bubble        bool `json:"bubble,omitempty"`
+		Camp          bool `json:"camp,omitempty"`
+		Spike         bool `json:"spike,omitempty"`
 	}
 
 	ReportList []*Report
@@ -24,17 +58,43 @@ type (
 		Character string    `json:"character"`
 	}
 
+	// IntelFeeder is a source of intel reports. FeedIntel blocks, sending reports until the context is cancelled.
 	IntelFeeder interface {
-		Feed(ctx context.Context, reps chan Report, errs chan error) (err error)
+		FeedIntel(ctx context.Context, reps chan<- Report, errs chan<- error) (err error)
 	}
 
+	// LocationFeeder is a source of character locations. FeedLocations blocks, sending locations until the context
+	// is cancelled.
 	LocationFeeder interface {
-		Feed(ctx context.Context, locs chan Locstat, errs chan error) (err error)
+		FeedLocations(ctx context.Context, locs chan<- Locstat, errs chan<- error) (err error)
 	}
 )
 
+// Hash identifies the report regardless of who heard it or where, so that copies of it can be found.
+// The message is compared without regard to case or spacing.
 func (r *Report) Hash() string {
-	return r.Message
+	return r.Reporter + "\x00" + strings.ToLower(strings.Join(strings.Fields(r.Message), " "))
+}
+
+// Merge records that a copy of the report was also seen by another listener or in another source
+func (r *Report) Merge(o *Report) {
+	r.addSeen(r.Listener, r.Source)
+	r.addSeen(o.Listener, o.Source)
+	for _, l := range o.Listeners {
+		r.addSeen(l, "")
+	}
+	for _, s := range o.Sources {
+		r.addSeen("", s)
+	}
+}
+
+func (r *Report) addSeen(listener, source string) {
+	if listener != "" && !containsString(r.Listeners, listener) {
+		r.Listeners = append(r.Listeners, listener)
+	}
+	if source != "" && !containsString(r.Sources, source) {
+		r.Sources = append(r.Sources, source)
+	}
 }
 
 func (r *Report) String() string {
@@ -60,3 +120,8 @@ func (rl ReportList) Less(i, j int) bool {
 func (rl ReportList) Swap(i, j int) {
 	rl[i], rl[j] = rl[j], rl[i]
 }
+
+// Hostile is true if the intel says anything about hostiles being there
+func (i *Intel) Hostile() bool {
+	return i.Pilots > 0 || len(i.Ships) > 0 || i.Bubble || i.Camp || i.Spike
+}
@@ -0,0 +1,363 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type (
+	// GamelogFeed follows each character through the notifications in their game logs. Unlike the Local chat
+	// channel these are written for every character, whether or not they have chat logging enabled.
+	GamelogFeed struct {
+		gamelogDirs []string
+	}
+
+	// gameWatch is a single watch over the game logs, with its own sessions
+	gameWatch struct {
+		sessions map[string]*gameSession
+		handle   gameLineHandler
+		ready    func()
+	}
+
+	// gameSession is the state of a single game log file, one per character per login
+	gameSession struct {
+		tail *logTail
+
+		// rules counts the dashed lines around the header, the header is finished after the second
+		rules    int
+		listener string
+
+		// system is the last system the character was seen in, and when
+		system     string
+		systemTime time.Time
+
+		// attackers holds when each attacker was last reported, so sustained combat is not reported every second
+		attackers map[string]time.Time
+	}
+
+	// GameLine is a single entry from a game log, in the form "[ 2006.01.02 15:04:05 ] (kind) Message"
+	GameLine struct {
+		Time    time.Time
+		Kind    string
+		Message string
+	}
+
+	// gameLineHandler is called for every new line of every game log. existing is true for the lines that had
+	// already been written when the watch started.
+	gameLineHandler func(gs *gameSession, gl GameLine, existing bool)
+)
+
+const (
+	// combatRepeat is how often the same attacker is reported while the fight goes on
+	combatRepeat = 30 * time.Second
+)
+
+var (
+	gameMarkup = regexp.MustCompile(`<[^>]*>`)
+
+	gameJump   = regexp.MustCompile(`^Jumping from (.+) to (.+?)\.?$`)
+	gameUndock = regexp.MustCompile(`^Undocking from (.+) to (.+) solar system\.?$`)
+	gameDock   = regexp.MustCompile(`^Requested to dock at (.+?)( station)?\.?$`)
+
+	// Only combat aimed at the listener is of interest, these capture the attacker
+	combatHit      = regexp.MustCompile(`^\d+ from (.+?)(?: - .*)?$`)
+	combatMiss     = regexp.MustCompile(`^(.+?) misses you completely`)
+	combatScramble = regexp.MustCompile(`^Warp (scramble|disruption) attempt from (.+?) to you`)
+
+	// combatEntity splits an attacker such as "Some Pilot[CORP](Sabre)", NPCs do not have a ship in brackets
+	combatEntity = regexp.MustCompile(`^(.+?)\s*(?:\[[^\]]*\])?\s*\(([^)]+)\)$`)
+
+	romanNumeral = regexp.MustCompile(`^[IVXL]+$`)
+)
+
+func (f *GamelogFeed) SetLogDir(dir string) error {
+	return f.SetLogDirs([]string{dir})
+}
+
+// SetLogDirs sets the EVE log directories whose game logs are watched, it takes effect the next time the feed starts
+func (f *GamelogFeed) SetLogDirs(dirs []string) error {
+	log.Printf("GL: setting log dirs to %v\n", dirs)
+	f.gamelogDirs = logSubdirs(dirs, "Gamelogs")
+	return nil
+}
+
+// FeedLocations watches the game logs and sends a Locstat whenever a character jumps, docks or undocks
+func (f *GamelogFeed) FeedLocations(ctx context.Context, locs chan<- Locstat, errs chan<- error) (err error) {
+	// Start with where everyone currently is
+	latest := make(map[string]Locstat)
+
+	return f.watch(ctx, errs, func(gs *gameSession, gl GameLine, existing bool) {
+		if !gs.locate(gl) {
+			return
+		}
+		loc := Locstat{
+			System:    gs.system,
+			Time:      gl.Time,
+			Character: gs.listener,
+		}
+		if existing {
+			if loc.Time.After(latest[loc.Character].Time) {
+				latest[loc.Character] = loc
+			}
+			return
+		}
+		select {
+		case locs <- loc:
+		case <-ctx.Done():
+		}
+	}, func() {
+		for c, l := range latest {
+			delete(latest, c)
+			select {
+			case locs <- l:
+			case <-ctx.Done():
+			}
+		}
+	})
+}
+
+// FeedIntel watches the game logs and sends a Report whenever a player attacks or tackles one of the characters,
+// placing the attacker in the system the character is in
+func (f *GamelogFeed) FeedIntel(ctx context.Context, reps chan<- Report, errs chan<- error) (err error) {
+	return f.watch(ctx, errs, func(gs *gameSession, gl GameLine, existing bool) {
+		if gs.locate(gl) || existing || gl.Kind != "combat" {
+			return
+		}
+		rep, ok := gs.combatReport(gl)
+		if !ok {
+			return
+		}
+		log.Printf("DEBUG: GL: Combat Report - %#v", rep)
+		select {
+		case reps <- rep:
+		case <-ctx.Done():
+		}
+	}, nil)
+}
+
+// watch runs a watcher over the game logs, passing every line to handle. Each watch keeps its own sessions so that
+// several can run over the same logs. ready is called once the existing logs have been read. Any directory that does
+// not exist yet is waited for.
+func (f *GamelogFeed) watch(ctx context.Context, errs chan<- error, handle gameLineHandler, ready func()) error {
+	report := func(err error) {
+		select {
+		case errs <- err:
+		case <-ctx.Done():
+		}
+	}
+	exists := func(dir string) bool {
+		fi, err := os.Stat(dir)
+		return err == nil && fi.IsDir()
+	}
+
+	log.Printf("GL: Starting the gamelog watcher! - DIRS: %v", f.gamelogDirs)
+
+	gw := &gameWatch{
+		sessions: make(map[string]*gameSession),
+		handle:   handle,
+		ready:    ready,
+	}
+	return newDirWatch("GL", f.gamelogDirs, exists, report).run(ctx, gw, nil, nil)
+}
+
+func (gw *gameWatch) attached(dirs []string, now time.Time) {
+	for _, dir := range dirs {
+		readExisting(dir, gw.sessions, now, gw.handle)
+	}
+	if gw.ready != nil {
+		gw.ready()
+	}
+}
+
+func (gw *gameWatch) detached(dir string) {
+	for p := range gw.sessions {
+		if filepath.Dir(p) == dir {
+			delete(gw.sessions, p)
+		}
+	}
+}
+
+func (gw *gameWatch) written(path string) {
+	checkGamelog(gw.sessions, path, false, gw.handle)
+}
+
+func (gw *gameWatch) prune(now time.Time) {
+	for p, s := range gw.sessions {
+		if s.tail.stale(now) {
+			delete(gw.sessions, p)
+		}
+	}
+}
+
+// readExisting reads the recent game logs so that the sessions continue from the end of each file
+func readExisting(dir string, sessions map[string]*gameSession, now time.Time, handle gameLineHandler) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Printf("GL: failed to list logs: %s", err)
+		return
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() || now.Sub(fi.ModTime()) > sessionStaleAfter {
+			continue
+		}
+		checkGamelog(sessions, filepath.Join(dir, fi.Name()), true, handle)
+	}
+}
+
+func checkGamelog(sessions map[string]*gameSession, path string, existing bool, handle gameLineHandler) {
+	gs, ok := sessions[path]
+	if !ok {
+		gs = &gameSession{tail: newLogTail(path)}
+		sessions[path] = gs
+	}
+
+	lines, restarted, err := gs.tail.Lines()
+	if err != nil {
+		log.Printf("DEBUG: GL: failed to read %s: %s", path, err)
+		return
+	}
+	if restarted {
+		*gs = gameSession{tail: gs.tail}
+	}
+
+	for _, text := range lines {
+		if gs.rules < 2 {
+			gs.readHeader(text)
+			continue
+		}
+
+		gl, err := ParseGameLine(text)
+		if err != nil {
+			continue
+		}
+		handle(gs, gl, existing)
+	}
+}
+
+// readHeader picks the listener out of the game log header
+func (gs *gameSession) readHeader(text string) {
+	text = cleanLine(text)
+	switch {
+	case text == "":
+	case isHeaderRule(text):
+		gs.rules++
+	default:
+		i := strings.IndexAny(text, ":：")
+		if i < 0 {
+			// This is the "Gamelog" title
+			return
+		}
+		value := strings.TrimSpace(strings.TrimLeft(text[i:], ":："))
+		if _, err := time.Parse(logTimeFormat, value); err == nil {
+			// The session start
+			return
+		}
+		gs.listener = value
+	}
+}
+
+// locate updates the system the character is in, returning true if the line was about their location
+func (gs *gameSession) locate(gl GameLine) bool {
+	switch {
+	case gameJump.MatchString(gl.Message):
+		gs.system = strings.TrimSpace(gameJump.FindStringSubmatch(gl.Message)[2])
+	case gameUndock.MatchString(gl.Message):
+		gs.system = strings.TrimSpace(gameUndock.FindStringSubmatch(gl.Message)[2])
+	case gameDock.MatchString(gl.Message):
+		if gs.system == "" {
+			gs.system = stationSystem(gameDock.FindStringSubmatch(gl.Message)[1])
+		}
+	default:
+		return false
+	}
+	gs.systemTime = gl.Time
+	return gs.system != ""
+}
+
+// combatReport turns a combat line aimed at the character into a report on the attacker. Lines about NPCs, combat
+// started by the character, and attackers that have been reported recently are skipped.
+func (gs *gameSession) combatReport(gl GameLine) (rep Report, ok bool) {
+	var attacker, action string
+	switch {
+	case combatScramble.MatchString(gl.Message):
+		m := combatScramble.FindStringSubmatch(gl.Message)
+		attacker, action = m[2], "warp "+m[1]
+	case combatHit.MatchString(gl.Message):
+		attacker, action = combatHit.FindStringSubmatch(gl.Message)[1], "shooting"
+	case combatMiss.MatchString(gl.Message):
+		attacker, action = combatMiss.FindStringSubmatch(gl.Message)[1], "shooting"
+	default:
+		return Report{}, false
+	}
+
+	m := combatEntity.FindStringSubmatch(strings.TrimSpace(attacker))
+	if m == nil {
+		return Report{}, false
+	}
+	name, ship := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+
+	if gs.attackers == nil {
+		gs.attackers = make(map[string]time.Time)
+	}
+	if last, seen := gs.attackers[name]; seen && gl.Time.Sub(last) < combatRepeat {
+		return Report{}, false
+	}
+	gs.attackers[name] = gl.Time
+
+	rep = Report{
+		Message:  fmt.Sprintf("%s %s", name, action),
+		Reporter: gs.listener,
+		Listener: gs.listener,
+		Source:   "gamelog",
+		Attacker: name,
+		Ship:     ship,
+		Time:     gl.Time,
+	}
+	// Until the character has jumped or undocked the log doesn't say where they are, the engine may know from Local
+	if gs.system != "" {
+		rep.Message = gs.system + " " + rep.Message
+		rep.System = gs.system
+	}
+
+	return rep, true
+}
+
+// stationSystem guesses the system from a station name such as "Jita IV - Moon 4 - Caldari Navy Assembly Plant"
+func stationSystem(station string) string {
+	name := strings.TrimSpace(strings.SplitN(station, " - ", 2)[0])
+	words := strings.Fields(name)
+	if len(words) > 1 && romanNumeral.MatchString(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// ParseGameLine splits a line from a game log into its parts, with any markup removed from the message
+func ParseGameLine(text string) (GameLine, error) {
+	text = cleanLine(text)
+
+	t, rest, err := splitTimestamp(text)
+	if err != nil {
+		return GameLine{}, err
+	}
+	rest = strings.TrimSpace(rest)
+
+	gl := GameLine{Time: t}
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.Index(rest, ")"); end > 0 {
+			gl.Kind = rest[1:end]
+			rest = rest[end+1:]
+		}
+	}
+	gl.Message = strings.TrimSpace(gameMarkup.ReplaceAllString(rest, ""))
+
+	return gl, nil
+}
@@ -0,0 +1,243 @@
+package feeds
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+type (
+	// ChatlogHeader holds the details written at the top of every EVE chat log
+	ChatlogHeader struct {
+		ChannelID      string    `json:"channelId"`
+		ChannelName    string    `json:"channelName"`
+		Listener       string    `json:"listener"`
+		SessionStarted time.Time `json:"sessionStarted"`
+		MOTD           string    `json:"motd"`
+	}
+
+	// ChatlogHeaderError describes why a chat log header could not be parsed
+	ChatlogHeaderError struct {
+		Line  int
+		Field string
+		Err   error
+	}
+
+	// headerParser consumes lines from the top of a chat log one at a time until the header has been read.
+	// Fields are recognised by their English label where possible. Localised clients use different labels,
+	// so any field that is not recognised is assigned by the position it appears in.
+	headerParser struct {
+		header ChatlogHeader
+		state  headerState
+		line   int
+
+		unlabelled []string
+	}
+
+	headerState int
+)
+
+const (
+	headerBefore headerState = iota
+	headerFields
+	headerMOTD
+	headerDone
+
+	// maxHeaderLines guards against reading an entire file that is not a chat log
+	maxHeaderLines = 16
+)
+
+var (
+	HeaderMalformed  = errors.New("malformed chat log header")
+	HeaderIncomplete = errors.New("chat log header is missing a field")
+	HeaderTruncated  = errors.New("chat log ended before the header was complete")
+
+	headerLabels = map[string]string{
+		"channelid":      "id",
+		"channelname":    "name",
+		"listener":       "listener",
+		"sessionstarted": "started",
+	}
+)
+
+func (e *ChatlogHeaderError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s (line %d)", e.Err, e.Field, e.Line)
+	}
+	return fmt.Sprintf("%s (line %d)", e.Err, e.Line)
+}
+
+func (e *ChatlogHeaderError) Unwrap() error {
+	return e.Err
+}
+
+// ParseChatlogHeader reads the header from a raw chat log, as written by the EVE client
+func ParseChatlogHeader(r io.Reader) (ChatlogHeader, error) {
+	dec := transform.NewReader(r, unicode.BOMOverride(unicode.UTF8.NewDecoder()))
+	sc := bufio.NewScanner(dec)
+
+	var p headerParser
+	for sc.Scan() {
+		if _, err := p.Add(sc.Text()); err != nil {
+			return ChatlogHeader{}, err
+		}
+		if p.Done() {
+			return p.header, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return ChatlogHeader{}, err
+	}
+
+	if p.state == headerMOTD {
+		// The header is complete, there just have not been any messages yet
+		return p.header, nil
+	}
+
+	return ChatlogHeader{}, &ChatlogHeaderError{Line: p.line, Err: HeaderTruncated}
+}
+
+// Add passes the next line of the log to the parser. consumed is false once the header is finished and the line
+// is a chat message that should be handled by the caller.
+func (p *headerParser) Add(text string) (consumed bool, err error) {
+	if p.state == headerDone {
+		return false, nil
+	}
+
+	p.line++
+	text = cleanLine(text)
+
+	switch p.state {
+	case headerBefore:
+		switch {
+		case text == "":
+		case isHeaderRule(text):
+			p.state = headerFields
+		default:
+			return true, &ChatlogHeaderError{Line: p.line, Err: HeaderMalformed}
+		}
+
+	case headerFields:
+		switch {
+		case text == "":
+		case isHeaderRule(text):
+			return true, p.finishFields()
+		default:
+			if p.line > maxHeaderLines {
+				return true, &ChatlogHeaderError{Line: p.line, Err: HeaderMalformed}
+			}
+			err = p.addField(text)
+			if err != nil {
+				return true, err
+			}
+		}
+
+	case headerMOTD:
+		if text == "" {
+			return true, nil
+		}
+		p.state = headerDone
+		if p.header.ChannelID == "local" {
+			// Local does not have a MOTD, its first message is the system change
+			return false, nil
+		}
+		cl, err := ParseChatLine(text)
+		if err != nil || cl.Sender != "EVE System" {
+			return false, nil
+		}
+		msg := cl.Message
+		// Drop the "Channel MOTD:" label in front of the text itself
+		if i := strings.IndexAny(msg, ":："); i >= 0 && strings.Contains(msg[:i], "MOTD") {
+			msg = strings.TrimSpace(strings.TrimLeft(msg[i:], ":："))
+		}
+		p.header.MOTD = msg
+	}
+
+	return true, nil
+}
+
+// Ready reports whether all of the header fields have been read. The MOTD may still be to come.
+func (p *headerParser) Ready() bool {
+	return p.state >= headerMOTD
+}
+
+// Done reports whether the parser has finished with the header entirely
+func (p *headerParser) Done() bool {
+	return p.state == headerDone
+}
+
+func (p *headerParser) Header() ChatlogHeader {
+	return p.header
+}
+
+func (p *headerParser) addField(text string) error {
+	i := strings.IndexAny(text, ":：")
+	if i < 0 {
+		return &ChatlogHeaderError{Line: p.line, Err: HeaderMalformed}
+	}
+	label := text[:i]
+	value := strings.TrimSpace(strings.TrimLeft(text[i:], ":："))
+
+	key := strings.ToLower(strings.Join(strings.Fields(label), ""))
+	switch headerLabels[key] {
+	case "id":
+		p.header.ChannelID = value
+	case "name":
+		p.header.ChannelName = value
+	case "listener":
+		p.header.Listener = value
+	case "started":
+		t, err := time.Parse(logTimeFormat, value)
+		if err != nil {
+			return &ChatlogHeaderError{Line: p.line, Field: "session started", Err: HeaderMalformed}
+		}
+		p.header.SessionStarted = t
+	default:
+		// The session start is the only value with a known shape so it can be found regardless of label
+		if t, err := time.Parse(logTimeFormat, value); err == nil {
+			p.header.SessionStarted = t
+			break
+		}
+		p.unlabelled = append(p.unlabelled, value)
+	}
+
+	return nil
+}
+
+// finishFields fills any missing fields from the unlabelled values, in the order the client writes them
+func (p *headerParser) finishFields() error {
+	missing := []*string{&p.header.ChannelID, &p.header.ChannelName, &p.header.Listener}
+	for _, m := range missing {
+		if *m == "" && len(p.unlabelled) > 0 {
+			*m = p.unlabelled[0]
+			p.unlabelled = p.unlabelled[1:]
+		}
+	}
+
+	switch {
+	case p.header.ChannelID == "":
+		return &ChatlogHeaderError{Line: p.line, Field: "channel id", Err: HeaderIncomplete}
+	case p.header.ChannelName == "":
+		return &ChatlogHeaderError{Line: p.line, Field: "channel name", Err: HeaderIncomplete}
+	case p.header.Listener == "":
+		return &ChatlogHeaderError{Line: p.line, Field: "listener", Err: HeaderIncomplete}
+	}
+
+	p.state = headerMOTD
+	return nil
+}
+
+func isHeaderRule(text string) bool {
+	return strings.Trim(text, "-") == ""
+}
+
+// cleanLine strips the byte order mark the client writes at the start of every line, along with any padding
+func cleanLine(text string) string {
+	return strings.TrimSpace(strings.TrimPrefix(text, "\ufeff"))
+}
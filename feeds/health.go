@@ -0,0 +1,96 @@
+package feeds
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type (
+	// ChannelActivity is when a listener last heard anything in a watched intel channel
+	ChannelActivity struct {
+		// Source matches the Source of the reports read from the channel
+		Source      string    `json:"source"`
+		ChannelID   string    `json:"channelId"`
+		Channel     string    `json:"channel"`
+		Listener    string    `json:"listener"`
+		LastMessage time.Time `json:"lastMessage"`
+	}
+
+	// ActivitySource is a feed that can report how recently each of its channels was heard from, including channels
+	// that have been silent since the feed started
+	ActivitySource interface {
+		Activity() []ChannelActivity
+	}
+
+	activityKey struct {
+		channelID string
+		listener  string
+	}
+)
+
+// Activity returns when each listener last heard each of the watched intel channels
+func (f *LogFeed) Activity() []ChannelActivity {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := make([]ChannelActivity, 0, len(f.activity))
+	for _, a := range f.activity {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Channel != list[j].Channel {
+			return list[i].Channel < list[j].Channel
+		}
+		return list[i].Listener < list[j].Listener
+	})
+
+	return list
+}
+
+// heard records that a listener was in a channel at the given time
+func (f *LogFeed) heard(h ChatlogHeader, t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.activity == nil {
+		f.activity = make(map[activityKey]ChannelActivity)
+	}
+	k := activityKey{channelID: h.ChannelID, listener: h.Listener}
+	a, ok := f.activity[k]
+	if ok && !t.After(a.LastMessage) {
+		return
+	}
+	f.activity[k] = ChannelActivity{
+		Source:      logSource(h),
+		ChannelID:   h.ChannelID,
+		Channel:     h.ChannelName,
+		Listener:    h.Listener,
+		LastMessage: t,
+	}
+}
+
+// forgetActivity drops the activity of channels that are no longer watched, and of listeners that have not been
+// heard from in so long that they have most likely logged off for the day
+func (f *LogFeed) forgetActivity(rooms []string, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for k, a := range f.activity {
+		watched := false
+		for _, room := range rooms {
+			if matchesChannel(room, ChatlogHeader{ChannelID: a.ChannelID, ChannelName: a.Channel}) {
+				watched = true
+				break
+			}
+		}
+		if !watched || now.Sub(a.LastMessage) > sessionStaleAfter {
+			delete(f.activity, k)
+		}
+	}
+}
+
+// logSource is the Source given to reports read from a chat log
+func logSource(h ChatlogHeader) string {
+	return fmt.Sprintf("log: %s", h.ChannelName)
+}
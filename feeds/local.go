@@ -0,0 +1,112 @@
+package feeds
+
+import (
+	"regexp"
+	"strings"
+)
+
+type (
+	// SystemValidator returns the proper name of a solar system, and false if there is no such system
+	SystemValidator func(name string) (string, bool)
+)
+
+var (
+	// systemSenders is the name the client gives its own notices in each language
+	systemSenders = []string{
+		"EVE System",
+		"EVE-System",
+		"Système EVE",
+		"Система EVE",
+		"EVEシステム",
+		"EVE系统",
+		"EVE 시스템",
+		"Sistema EVE",
+	}
+
+	// localChanges match the notice written to Local when a character changes system, capturing the system name
+	localChanges = []*regexp.Regexp{
+		// English
+		regexp.MustCompile(`(?i)^Channel changed to Local\s*[:：]\s*(.+)$`),
+		// German
+		regexp.MustCompile(`(?i)^Chatkanal geändert zu Lokal\s*[:：]\s*(.+)$`),
+		// French
+		regexp.MustCompile(`(?i)^Canal changé en Local\s*[:：]\s*(.+)$`),
+		// Russian
+		regexp.MustCompile(`(?i)^Канал измен[её]н на Локальный\s*[:：]\s*(.+)$`),
+		// Japanese, where the system name comes before the verb
+		regexp.MustCompile(`^チャンネル名が\s*ローカル\s*[:：]\s*(.+?)\s*に変更されました$`),
+		// Chinese
+		regexp.MustCompile(`^频道更换为本地\s*[:：]\s*(.+)$`),
+		// Korean
+		regexp.MustCompile(`^채널이\s*지역\s*[:：]\s*(.+?)\s*\(으\)로 변경되었습니다$`),
+		// Spanish
+		regexp.MustCompile(`(?i)^Canal cambiado a Local\s*[:：]\s*(.+)$`),
+	}
+)
+
+// SetSystemValidator sets the check that a system name read from Local is a real system. Without one any name in a
+// recognised notice is accepted.
+func (f *LogFeed) SetSystemValidator(valid SystemValidator) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.validSystem = valid
+}
+
+func (f *LogFeed) systemValidator() SystemValidator {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.validSystem
+}
+
+// parseLocalMessage reads the system a character moved to from the notice the client writes to Local, in any of
+// the client languages. Other messages return an empty Locstat.
+func parseLocalMessage(cl ChatLine, valid SystemValidator) (loc Locstat) {
+	if !isSystemSender(cl.Sender) {
+		//	Not a location update, return an empty message
+		return Locstat{}
+	}
+
+	name := ""
+	for _, re := range localChanges {
+		if m := re.FindStringSubmatch(cl.Message); m != nil {
+			name = m[1]
+			break
+		}
+	}
+	if name == "" && valid != nil {
+		// An unknown wording, the system name is most likely at the end after a colon
+		i := strings.LastIndexAny(cl.Message, ":：")
+		if i < 0 {
+			return Locstat{}
+		}
+		name = strings.TrimLeft(cl.Message[i:], ":：")
+	}
+
+	// Some clients mark the system with a trailing asterisk
+	name = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(name), "*"))
+	if name == "" {
+		return Locstat{}
+	}
+	if valid != nil {
+		var ok bool
+		if name, ok = valid(name); !ok {
+			return Locstat{}
+		}
+	}
+
+	// Character will get populated by parent method
+	return Locstat{
+		System:    name,
+		Time:      cl.Time,
+		Character: "",
+	}
+}
+
+func isSystemSender(sender string) bool {
+	for _, s := range systemSenders {
+		if strings.EqualFold(sender, s) {
+			return true
+		}
+	}
+	return false
+}
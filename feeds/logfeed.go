@@ -1,44 +1,67 @@
 package feeds
 
 import (
-	"bufio"
 	"context"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/radovskyb/watcher"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
 )
 
 type (
 	LogFeed struct {
-		watching   bool
-		chatlogDir string
-		roomnames  []string
+		// mu guards the settings below, which can be changed while the feed is running
+		mu          sync.Mutex
+		chatlogDirs []string
+		roomnames   []string
+		backfill    time.Duration
+		validSystem SystemValidator
+
+		// watches holds the running watches so they can be told about changes to the settings
+		watches map[*chatWatch]struct{}
+
+		// activity holds when each listener last heard each intel channel
+		activity map[activityKey]ChannelActivity
+	}
+
+	// chatWatch is a single watch over the chat logs. Intel channels and Local are watched separately, each with
+	// their own sessions, so that a LogFeed can be both an IntelFeeder and a LocationFeeder.
+	chatWatch struct {
+		feed  *LogFeed
+		local bool
+
+		// dirs watches the log directories
+		dirs *dirWatch
+		// send passes reports on from the watch
+		send func([]Report, []Locstat)
+
+		// These are the feed settings the watch is currently using
+		rooms    []string
+		backfill time.Duration
+
+		// changed is signalled when the feed settings are updated
+		changed chan struct{}
 
-		loglines map[string]uint64
+		// sessions holds the state of each chat log file, keyed by its path
+		sessions map[string]*chatSession
 	}
 )
 
 var (
 	LogFilesNotFound       = errors.New("failed to locate eve log directory, please set the correct directory in the settings")
+	WaitingForLogDir       = errors.New("waiting for the eve log directory to appear, check the directory in the settings")
 	PlatformNotImplemented = errors.New("platform not yet implemented")
 	PlatformNotSupported   = errors.New("platform requires manual log file directory selection")
-
-	AlreadyWatching = errors.New("cannot change log dir once already watching")
-
-	codec = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
 )
 
 const (
 	logTimeFormat = "2006.01.02 15:04:05"
+
+	// logDirPollInterval is how often a missing log directory is checked for
+	logDirPollInterval = 5 * time.Second
 )
 
 func (f *LogFeed) CheckLogDir(dir string) (valid bool) {
@@ -55,7 +78,7 @@ func (f *LogFeed) CheckLogDir(dir string) (valid bool) {
 		ls[i] = f.Name()
 	}
 
-	log.Printf("log watcher directory check", ls)
+	log.Printf("log watcher directory check: %v", ls)
 
 	valid = true
 
@@ -76,219 +99,312 @@ func (f *LogFeed) CheckLogDir(dir string) (valid bool) {
 	return valid
 }
 
+// SetLogDir sets a single EVE log directory to watch
 func (f *LogFeed) SetLogDir(dir string) error {
-	log.Printf("LW: setting log dir to %v\n", dir)
-	if f.watching {
-		return AlreadyWatching
-	}
-	f.chatlogDir = filepath.Join(dir, "Chatlogs")
+	return f.SetLogDirs([]string{dir})
+}
+
+// SetLogDirs sets the EVE log directories to watch, for players running clients with separate Documents folders or
+// Wine prefixes. They can be changed while the feed is running, in which case the running watches move over to the
+// new directories.
+func (f *LogFeed) SetLogDirs(dirs []string) error {
+	log.Printf("LW: setting log dirs to %v\n", dirs)
+	f.mu.Lock()
+	f.chatlogDirs = logSubdirs(dirs, "Chatlogs")
+	f.mu.Unlock()
+
+	f.notify()
 	return nil
 }
 
+// SetChatRooms sets the intel channels to watch, by name in any case or by channel ID. It can be changed while the
+// feed is running, channels that are still watched keep their place in their logs.
 func (f *LogFeed) SetChatRooms(rooms []string) {
-	f.roomnames = rooms
+	f.mu.Lock()
+	f.roomnames = append([]string(nil), rooms...)
+	f.mu.Unlock()
+
+	f.notify()
 }
 
 func (f *LogFeed) GetChatRooms() (rooms []string) {
-	return f.roomnames
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.roomnames...)
+}
+
+// settings returns a consistent copy of the feed settings
+func (f *LogFeed) settings() (dirs []string, rooms []string, backfill time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.chatlogDirs...), append([]string(nil), f.roomnames...), f.backfill
 }
 
-func (f *LogFeed) Feed(ctx context.Context, reps chan<- Report, locs chan<- Locstat, errs chan<- error) (err error) {
+// notify tells every running watch that the settings have changed
+func (f *LogFeed) notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	valid := f.CheckLogDir(filepath.Dir(f.chatlogDir))
-	if !valid {
-		return LogFilesNotFound
+	for cw := range f.watches {
+		select {
+		case cw.changed <- struct{}{}:
+		default:
+			// Already has a change waiting
+		}
 	}
+}
 
-	log.Printf("LW: Starting the logwatcher! - DIR: %s", f.chatlogDir)
+func (f *LogFeed) addWatch(cw *chatWatch) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 
-	if f.loglines == nil {
-		f.loglines = make(map[string]uint64)
+	if f.watches == nil {
+		f.watches = make(map[*chatWatch]struct{})
 	}
+	f.watches[cw] = struct{}{}
+}
 
-	w := watcher.New()
-	w.FilterOps(watcher.Create, watcher.Write)
-	go func() {
-		for {
-			select {
-			case event := <-w.Event:
-				rs, ls := f.checkLogFile(event)
-				for _, r := range rs {
-					log.Println("rep")
-					reps <- r
-				}
-				for _, l := range ls {
-					log.Println("loc")
-					locs <- l
-				}
+func (f *LogFeed) removeWatch(cw *chatWatch) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.watches, cw)
+}
 
-			case err := <-w.Error:
-				errs <- err
-			case <-w.Closed:
+// FeedIntel watches the configured intel channels, sending a Report for every message
+func (f *LogFeed) FeedIntel(ctx context.Context, reps chan<- Report, errs chan<- error) (err error) {
+	return f.watch(ctx, false, errs, func(rs []Report, _ []Locstat) {
+		for _, r := range rs {
+			select {
+			case reps <- r:
+			case <-ctx.Done():
 				return
+			}
+		}
+	})
+}
+
+// FeedLocations watches the Local channel of every character, sending a Locstat each time one changes system
+func (f *LogFeed) FeedLocations(ctx context.Context, locs chan<- Locstat, errs chan<- error) (err error) {
+	return f.watch(ctx, true, errs, func(_ []Report, ls []Locstat) {
+		for _, l := range ls {
+			select {
+			case locs <- l:
 			case <-ctx.Done():
-				w.Close()
+				return
 			}
 		}
-	}()
+	})
+}
+
+func (f *LogFeed) watch(ctx context.Context, local bool, errs chan<- error, send func([]Report, []Locstat)) (err error) {
 
-	if err := w.Add(f.chatlogDir); err != nil {
-		log.Fatalln(err)
+	cw := &chatWatch{
+		feed:     f,
+		local:    local,
+		send:     send,
+		changed:  make(chan struct{}, 1),
+		sessions: make(map[string]*chatSession),
 	}
+	var dirs []string
+	dirs, cw.rooms, cw.backfill = f.settings()
 
-	if err := w.Start(500 * time.Millisecond); err != nil {
-		log.Fatalln(err)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		case <-ctx.Done():
+		}
 	}
+	exists := func(dir string) bool {
+		return f.CheckLogDir(filepath.Dir(dir))
+	}
+	cw.dirs = newDirWatch("LW", dirs, exists, report)
 
-	return nil
+	log.Printf("LW: Starting the logwatcher! - DIRS: %v - Local: %v", dirs, local)
+
+	f.addWatch(cw)
+	defer f.removeWatch(cw)
+
+	return cw.dirs.run(ctx, cw, cw.changed, cw.reconfigure)
 }
 
-func (f *LogFeed) checkLogFile(fileInfo os.FileInfo) (reps []Report, locs []Locstat) {
+// attached catches up on anything that was said in new log directories, so the map doesn't start grey
+func (cw *chatWatch) attached(_ []string, now time.Time) {
+	cw.send(cw.backfillLogs(now))
+}
+
+// detached forgets all of the sessions in a log directory
+func (cw *chatWatch) detached(dir string) {
+	for p := range cw.sessions {
+		if filepath.Dir(p) == dir {
+			delete(cw.sessions, p)
+		}
+	}
+}
+
+func (cw *chatWatch) written(path string) {
+	cw.send(cw.checkLogFile(path))
+}
+
+func (cw *chatWatch) prune(now time.Time) {
+	cw.pruneSessions(now)
+	if !cw.local {
+		cw.feed.forgetActivity(cw.rooms, now)
+	}
+}
+
+func (cw *chatWatch) checkLogFile(path string) (reps []Report, locs []Locstat) {
 
 	log.Println("DEBUG: LW: Event 1")
 
-	lf, err := os.Open(filepath.Join(f.chatlogDir, fileInfo.Name()))
+	cf := cw.session(path)
+	if cf.ignored {
+		return nil, nil
+	}
+
+	lines, restarted, err := cf.tail.Lines()
 	if err != nil {
+		log.Printf("DEBUG: LW: failed to read %s: %s", cf.tail.path, err)
 		return nil, nil
 	}
-	defer lf.Close()
-	lfe := transform.NewReader(lf, codec.NewDecoder())
-	blf := bufio.NewScanner(lfe)
-
-	isLocal := false
-	chanName := ""
-	listener := ""
-	var skip uint64 = 12
-
-	var line uint64 = 0
-	for blf.Scan() {
-		line++
-		text := blf.Text()
-
-		// Now remove the weird BOM like thing (WTF is this CCP?)
-		if len(text) > 3 {
-			text = text[3:]
-		}
+	if restarted {
+		// The file was truncated or replaced so the header will be read again
+		cf.reset()
+	}
 
-		switch {
-		case line == 7:
-			//	This is the channel id, use it to check local channel regardless of lang
-			isLocal = strings.TrimSpace(strings.Split(text, ":")[1]) == "local"
-			if isLocal {
-				log.Println("DEBUG: LW: Event LOCAL")
-			}
-		case line == 8:
-			//	This is the channel name
-			chanName = strings.TrimSpace(strings.Split(text, ":")[1])
-			log.Printf("DEBUG: LW: Event - %s", chanName)
-			if lim, ok := f.loglines[chanName]; ok {
-				skip = lim
-			} else {
-				if isLocal {
-					// if local we do care about the first log line
-					skip = 12
-				} else {
-					// if not local we dont want to capture the MOTD
-					skip = 13
-				}
+	for _, text := range lines {
+		if !cf.header.Done() {
+			ready := cf.header.Ready()
+			consumed, err := cf.header.Add(text)
+			if err != nil {
+				log.Printf("DEBUG: LW: ignoring %s: %s", cf.tail.path, err)
+				cf.ignored = true
+				return reps, locs
 			}
-			if !isLocal {
-				//	Now check if the channel is one we care about, if not then just return early :)
-				found := false
-				for _, room := range f.roomnames {
-					log.Printf("DEBUG LW: Checking %s = %s => %v", chanName, room, chanName == room)
-					if chanName == room {
-						found = true
-						break
-					}
+			if !ready && cf.header.Ready() {
+				if !cw.wantSession(cf) {
+					//	Not a channel we care about, so stop reading it
+					cf.ignored = true
+					return reps, locs
 				}
-				log.Printf("DEBUG: LW: Found - %v", found)
-				if !found {
-					return nil, nil
+				if !cf.isLocal {
+					h := cf.header.Header()
+					cw.feed.heard(h, h.SessionStarted)
 				}
 			}
+			if consumed {
+				continue
+			}
+		}
 
-		case line == 9:
-			//	This is the listener line
-			listener = strings.TrimSpace(strings.Split(text, ":")[1])
-			log.Printf("DEBUG: LW: Listener - %v", listener)
-		case line >= skip:
-			//	This is a line we want to report
-			if len(text) < 24 {
-				// Happens occasionally
-				log.Println("Short Line")
-				break
+		//	This is a line we want to report
+		cl, err := ParseChatLine(text)
+		if err != nil {
+			// Happens occasionally, blank lines and multi line messages for example
+			log.Printf("DEBUG: LW: skipping line: %s", err)
+			continue
+		}
+
+		h := cf.header.Header()
+		if cf.isLocal {
+			loc := parseLocalMessage(cl, cw.feed.systemValidator())
+			if loc.System != "" {
+				loc.Character = h.Listener
+				locs = append(locs, loc)
 			}
-			if isLocal {
-				loc := f.parseLocalMessage(text)
-				if loc.System != "" {
-					loc.Character = listener
-					locs = append(locs, loc)
-				}
-			} else {
-				//	Dealing with an intel room
-				log.Println("DEBUG: LW: Intel message")
-				rep := f.parseIntelMessage(text)
-				if rep != (Report{}) {
-					rep.Listener = listener
-					rep.Source = fmt.Sprintf("log: %s", chanName)
-					log.Printf("DEBUG: LW: Making Report - %#v", rep)
-					reps = append(reps, rep)
-				}
+		} else {
+			//	Dealing with an intel room
+			log.Println("DEBUG: LW: Intel message")
+			cw.feed.heard(h, cl.Time)
+			rep := parseIntelMessage(cl)
+			if rep.Message != "" {
+				rep.Listener = h.Listener
+				rep.Source = logSource(h)
+				log.Printf("DEBUG: LW: Making Report - %#v", rep)
+				reps = append(reps, rep)
 			}
 		}
 	}
 
-	f.loglines[chanName] = line + 1
-
 	log.Printf("DEBUG: LW: Making %d intel reports and %d location reports", len(reps), len(locs))
 
 	return reps, locs
 }
 
-func (f *LogFeed) parseLocalMessage(msg string) (loc Locstat) {
-	t, sender, msgp, err := f.splitLogMessage(msg)
-	if err != nil {
-		log.Println(fmt.Errorf("failed to decode timestamp of local log message; '%s': %w", msg, err))
-		return Locstat{}
-	}
+// wantSession reports whether the session is one this watch handles, either Local or one of the intel channels.
+// It is only valid once the header fields have been read, and also marks whether the session is a Local channel.
+func (cw *chatWatch) wantSession(s *chatSession) bool {
+	h := s.header.Header()
 
-	if sender != "EVE System" {
-		//	Not a location update, return an empty message
-		return Locstat{}
+	//	Use the channel id to check for local regardless of lang
+	s.isLocal = h.ChannelID == "local"
+	if s.isLocal || cw.local {
+		log.Printf("DEBUG: LW: Event LOCAL - %v", s.isLocal)
+		return s.isLocal && cw.local
 	}
 
-	// Character will get populated by parent method
-	return Locstat{
-		System:    strings.TrimSpace(strings.Split(msgp, ":")[1]),
-		Time:      t,
-		Character: "",
+	log.Printf("DEBUG: LW: Event - %s (%s) - Listener %s", h.ChannelName, h.ChannelID, h.Listener)
+	for _, room := range cw.rooms {
+		if matchesChannel(room, h) {
+			return true
+		}
 	}
+	return false
 }
 
-func (f *LogFeed) parseIntelMessage(msg string) (rep Report) {
-	t, sender, msgp, err := f.splitLogMessage(msg)
+// reconfigure picks up the latest feed settings. Sessions for channels that are no longer watched are dropped, while
+// channels that are newly watched are caught up as though the feed had just started.
+func (cw *chatWatch) reconfigure(now time.Time) {
+	dirs, rooms, backfill := cw.feed.settings()
 
-	if err != nil {
-		log.Println(fmt.Errorf("failed to decode timestamp of intel log message; '%s': %w", msg, err))
-		return Report{}
+	cw.rooms = rooms
+	cw.backfill = backfill
+	if !cw.local {
+		cw.feed.forgetActivity(rooms, now)
+	}
+
+	for p, s := range cw.sessions {
+		if !s.header.Ready() || s.isLocal {
+			continue
+		}
+		if cw.wantSession(s) == s.ignored {
+			delete(cw.sessions, p)
+		}
 	}
 
-	rep = Report{
-		Message:  msgp,
-		Reporter: sender,
-		Time:     t,
+	// Any new directories are caught up as they are attached, otherwise catch up on the new channels here
+	if !cw.dirs.setDirs(cw, dirs, now) && len(cw.dirs.attached) > 0 {
+		cw.send(cw.backfillLogs(now))
 	}
+}
 
-	return rep
+func parseIntelMessage(cl ChatLine) (rep Report) {
+	return Report{
+		Message:  cl.Message,
+		Reporter: cl.Sender,
+		Time:     cl.Time,
+	}
 }
 
-func (f *LogFeed) splitLogMessage(msg string) (t time.Time, sender string, message string, err error) {
-	dts := msg[2:21]
-	tme, err := time.Parse(logTimeFormat, dts)
-	if err != nil {
-		return time.Time{}, "", "", err
+// logSubdirs returns the named subdirectory of each EVE log directory, skipping blanks and duplicates
+func logSubdirs(dirs []string, sub string) []string {
+	subdirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if strings.TrimSpace(dir) == "" {
+			continue
+		}
+		p := filepath.Join(dir, sub)
+		if !containsString(subdirs, p) {
+			subdirs = append(subdirs, p)
+		}
+	}
+	return subdirs
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
 	}
-	s := strings.Split(msg[24:], ">")
-	return tme, strings.TrimSpace(s[0]), strings.TrimSpace(s[1]), nil
+	return false
 }
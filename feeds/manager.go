@@ -0,0 +1,278 @@
+package feeds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Manager runs any number of intel and location feeders, merging their output into a single pair of channels.
+	// Each feeder runs with its own context so it can be started and stopped on its own.
+	Manager struct {
+		mu sync.Mutex
+
+		reps chan<- Report
+		locs chan<- Locstat
+		errs chan<- error
+
+		feeders map[string]*managedFeeder
+	}
+
+	// FeederStatus describes the state of a single registered feeder
+	FeederStatus struct {
+		Name        string    `json:"name"`
+		Running     bool      `json:"running"`
+		Error       string    `json:"error,omitempty"`
+		LastMessage time.Time `json:"lastMessage"`
+	}
+
+	managedFeeder struct {
+		intel    IntelFeeder
+		location LocationFeeder
+
+		cancel context.CancelFunc
+		done   chan struct{}
+
+		status FeederStatus
+	}
+)
+
+var (
+	FeederExists     = errors.New("a feeder with this name is already registered")
+	FeederNotFound   = errors.New("no feeder registered with this name")
+	FeederRunning    = errors.New("feeder is already running")
+	FeederNotRunning = errors.New("feeder is not running")
+)
+
+func NewManager(reps chan<- Report, locs chan<- Locstat, errs chan<- error) *Manager {
+	return &Manager{
+		reps:    reps,
+		locs:    locs,
+		errs:    errs,
+		feeders: make(map[string]*managedFeeder),
+	}
+}
+
+// RegisterIntel adds an intel feeder under the given name, it is not started until Start or StartAll is called
+func (m *Manager) RegisterIntel(name string, feeder IntelFeeder) error {
+	return m.register(name, &managedFeeder{intel: feeder})
+}
+
+// RegisterLocation adds a location feeder under the given name, it is not started until Start or StartAll is called
+func (m *Manager) RegisterLocation(name string, feeder LocationFeeder) error {
+	return m.register(name, &managedFeeder{location: feeder})
+}
+
+func (m *Manager) register(name string, mf *managedFeeder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeders[name]; ok {
+		return FeederExists
+	}
+	mf.status.Name = name
+	m.feeders[name] = mf
+
+	return nil
+}
+
+// Start runs the named feeder until Stop is called or ctx is cancelled
+func (m *Manager) Start(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mf, ok := m.feeders[name]
+	if !ok {
+		return FeederNotFound
+	}
+	if mf.status.Running {
+		return FeederRunning
+	}
+
+	fctx, cancel := context.WithCancel(ctx)
+	mf.cancel = cancel
+	mf.done = make(chan struct{})
+	mf.status.Running = true
+	mf.status.Error = ""
+
+	// Everything the feeder produces passes through here so the status can be kept up to date. The forwarders run
+	// until the feeder has returned and everything it sent has been passed on, or the parent context is cancelled.
+	var forwarders sync.WaitGroup
+	forwarders.Add(2)
+
+	errs := make(chan error, 8)
+	go func() {
+		defer forwarders.Done()
+		m.forwardErrors(ctx, name, errs)
+	}()
+
+	var run func() error
+	var closeOutput func()
+	if mf.intel != nil {
+		reps := make(chan Report, 16)
+		go func() {
+			defer forwarders.Done()
+			m.forwardReports(ctx, name, reps)
+		}()
+		run = func() error { return mf.intel.FeedIntel(fctx, reps, errs) }
+		closeOutput = func() { close(reps) }
+	} else {
+		locs := make(chan Locstat, 16)
+		go func() {
+			defer forwarders.Done()
+			m.forwardLocations(ctx, name, locs)
+		}()
+		run = func() error { return mf.location.FeedLocations(fctx, locs, errs) }
+		closeOutput = func() { close(locs) }
+	}
+
+	go func() {
+		defer close(mf.done)
+		err := run()
+
+		// The feeder has returned so it won't send anything else, pass on whatever it left behind
+		closeOutput()
+		close(errs)
+		forwarders.Wait()
+
+		m.mu.Lock()
+		mf.status.Running = false
+		if err != nil {
+			mf.status.Error = err.Error()
+		}
+		m.mu.Unlock()
+
+		if err != nil {
+			log.Printf("FM: feeder %s stopped: %s", name, err)
+			m.sendError(ctx, fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+
+	return nil
+}
+
+// Stop cancels the named feeder and waits for it to finish, including passing on anything it had already sent
+func (m *Manager) Stop(name string) error {
+	m.mu.Lock()
+	mf, ok := m.feeders[name]
+	if !ok {
+		m.mu.Unlock()
+		return FeederNotFound
+	}
+	if !mf.status.Running {
+		m.mu.Unlock()
+		return FeederNotRunning
+	}
+	cancel, done := mf.cancel, mf.done
+	m.mu.Unlock()
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// StartAll starts every registered feeder that is not already running
+func (m *Manager) StartAll(ctx context.Context) {
+	for _, name := range m.names() {
+		err := m.Start(ctx, name)
+		if err != nil && err != FeederRunning {
+			log.Printf("FM: failed to start %s: %s", name, err)
+		}
+	}
+}
+
+// StopAll stops every running feeder
+func (m *Manager) StopAll() {
+	for _, name := range m.names() {
+		err := m.Stop(name)
+		if err != nil && err != FeederNotRunning {
+			log.Printf("FM: failed to stop %s: %s", name, err)
+		}
+	}
+}
+
+// Status returns the state of every registered feeder, ordered by name
+func (m *Manager) Status() []FeederStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := make([]FeederStatus, 0, len(m.feeders))
+	for _, mf := range m.feeders {
+		st = append(st, mf.status)
+	}
+	sort.Slice(st, func(i, j int) bool {
+		return st[i].Name < st[j].Name
+	})
+
+	return st
+}
+
+func (m *Manager) names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.feeders))
+	for name := range m.feeders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (m *Manager) touch(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mf, ok := m.feeders[name]; ok {
+		// Messages are flowing again so whatever went wrong has recovered
+		mf.status.LastMessage = time.Now()
+		mf.status.Error = ""
+	}
+}
+
+func (m *Manager) forwardReports(ctx context.Context, name string, in <-chan Report) {
+	for r := range in {
+		m.touch(name)
+		select {
+		case m.reps <- r:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) forwardLocations(ctx context.Context, name string, in <-chan Locstat) {
+	for l := range in {
+		m.touch(name)
+		select {
+		case m.locs <- l:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) forwardErrors(ctx context.Context, name string, in <-chan error) {
+	for err := range in {
+		m.mu.Lock()
+		if mf, ok := m.feeders[name]; ok {
+			mf.status.Error = err.Error()
+		}
+		m.mu.Unlock()
+		m.sendError(ctx, fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+func (m *Manager) sendError(ctx context.Context, err error) {
+	select {
+	case m.errs <- err:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,115 @@
+package feeds
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	// chatSession is the state of a single chat log file. EVE opens a new file for every channel each time a
+	// character logs in, so a file represents one session of one character listening to one channel.
+	chatSession struct {
+		tail *logTail
+
+		// These are taken from the file name and are available before the header has been read
+		fileChannel string
+		fileStarted time.Time
+		characterID string
+
+		header headerParser
+
+		isLocal bool
+		ignored bool
+	}
+)
+
+const (
+	logFileTimeFormat = "20060102_150405"
+
+	// sessionStaleAfter is how long a session file can go without being written before its state is discarded
+	sessionStaleAfter = 24 * time.Hour
+)
+
+func newChatSession(path string) *chatSession {
+	s := &chatSession{tail: newLogTail(path)}
+	s.fileChannel, s.fileStarted, s.characterID, _ = parseChatlogName(filepath.Base(path))
+	return s
+}
+
+// parseChatlogName splits a chat log file name of the form <Channel>_<date>_<time>_<charID>.txt.
+// Older clients do not append the character id. Channel names can themselves contain underscores.
+func parseChatlogName(name string) (channel string, started time.Time, charID string, ok bool) {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	parts := strings.Split(name, "_")
+
+	// Try with a character id first, and then without one
+	for _, n := range []int{3, 2} {
+		if len(parts) <= n {
+			continue
+		}
+		ts := parts[len(parts)-n] + "_" + parts[len(parts)-n+1]
+		t, err := time.Parse(logFileTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		if n == 3 {
+			charID = parts[len(parts)-1]
+		}
+		return strings.Join(parts[:len(parts)-n], "_"), t, charID, true
+	}
+
+	return name, time.Time{}, "", false
+}
+
+// reset clears everything learned from the file so the header is read again
+func (s *chatSession) reset() {
+	s.header = headerParser{}
+	s.isLocal = false
+	s.ignored = false
+}
+
+// supersedes reports whether s is a newer session for the same character and channel as o
+func (s *chatSession) supersedes(o *chatSession) bool {
+	if s == o || s.characterID == "" || s.characterID != o.characterID {
+		return false
+	}
+	if s.fileChannel != o.fileChannel {
+		return false
+	}
+	return s.fileStarted.After(o.fileStarted)
+}
+
+// session returns the session for the given chat log, creating it if this is the first time it has been seen.
+// Creating a session will discard any older session of the same character in the same channel.
+func (cw *chatWatch) session(path string) *chatSession {
+	if s, ok := cw.sessions[path]; ok {
+		return s
+	}
+
+	s := newChatSession(path)
+	for p, o := range cw.sessions {
+		switch {
+		case s.supersedes(o):
+			log.Printf("DEBUG: LW: session %s replaced by %s", filepath.Base(p), filepath.Base(path))
+			delete(cw.sessions, p)
+		case o.supersedes(s):
+			// A write to an old session, this character has already moved on to a newer file
+			s.ignored = true
+		}
+	}
+	cw.sessions[path] = s
+
+	return s
+}
+
+// pruneSessions drops the state of sessions that have gone stale
+func (cw *chatWatch) pruneSessions(now time.Time) {
+	for p, s := range cw.sessions {
+		if s.tail.stale(now) {
+			log.Printf("DEBUG: LW: dropping stale session %s", filepath.Base(p))
+			delete(cw.sessions, p)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package feeds
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+)
+
+type (
+	// logTail follows a single log file, remembering how far into the file it has read so that each
+	// call only decodes the bytes that have been appended since the previous call.
+	logTail struct {
+		path string
+
+		// info is the file info seen at the last read, used to detect the file being replaced
+		info os.FileInfo
+		// offset is the raw byte offset into the file up to which content has been decoded
+		offset int64
+		// partial holds a trailing line that has not yet been terminated by a newline
+		partial string
+
+		utf16 bool
+	}
+)
+
+var (
+	utf16BOM = []byte{0xff, 0xfe}
+)
+
+func newLogTail(path string) *logTail {
+	return &logTail{path: path}
+}
+
+// Lines returns any complete lines that have been written to the file since the last call.
+// Truncated or replaced files are read again from the start, in which case restarted is true.
+func (t *logTail) Lines() (lines []string, restarted bool, err error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if t.info != nil && (!os.SameFile(t.info, fi) || fi.Size() < t.offset) {
+		// The file has been replaced or truncated, start over
+		t.reset()
+		restarted = true
+	}
+	t.info = fi
+
+	if fi.Size() == t.offset {
+		return nil, restarted, nil
+	}
+
+	if t.offset == 0 {
+		// Chat logs are UTF-16 with a BOM, game logs are plain UTF-8. Nothing is read until there is enough of the
+		// file to tell which.
+		if fi.Size() < int64(len(utf16BOM)) {
+			return nil, restarted, nil
+		}
+		bom := make([]byte, 2)
+		n, err := io.ReadFull(f, bom)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, restarted, err
+		}
+		t.utf16 = n == 2 && bytes.Equal(bom, utf16BOM)
+		if t.utf16 {
+			t.offset = 2
+		}
+	}
+
+	_, err = f.Seek(t.offset, io.SeekStart)
+	if err != nil {
+		return nil, restarted, err
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(f, fi.Size()-t.offset))
+	if err != nil {
+		return nil, restarted, err
+	}
+
+	// Never split a UTF-16 code unit or surrogate pair, leave the remainder for the next read
+	if t.utf16 {
+		if len(raw)%2 != 0 {
+			raw = raw[:len(raw)-1]
+		}
+		if n := len(raw); n >= 2 && raw[n-1]&0xfc == 0xd8 {
+			raw = raw[:n-2]
+		}
+	}
+
+	text, err := t.decoder().Bytes(raw)
+	if err != nil {
+		return nil, restarted, err
+	}
+	t.offset += int64(len(raw))
+
+	return t.split(string(text)), restarted, nil
+}
+
+func (t *logTail) decoder() *encoding.Decoder {
+	if t.utf16 {
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+	}
+	return unicode.UTF8.NewDecoder()
+}
+
+// split breaks the decoded text into lines, holding back any unterminated trailing line
+func (t *logTail) split(text string) (lines []string) {
+	text = t.partial + text
+	t.partial = ""
+
+	parts := strings.Split(text, "\n")
+	// The final element is either empty or a line that is still being written
+	t.partial = parts[len(parts)-1]
+	parts = parts[:len(parts)-1]
+
+	lines = make([]string, len(parts))
+	for i, p := range parts {
+		lines[i] = strings.TrimRight(p, "\r")
+	}
+	return lines
+}
+
+// stale reports whether the file has not been written to recently, or has gone
+func (t *logTail) stale(now time.Time) bool {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return true
+	}
+	return now.Sub(fi.ModTime()) > sessionStaleAfter
+}
+
+func (t *logTail) reset() {
+	t.info = nil
+	t.offset = 0
+	t.partial = ""
+	t.utf16 = false
+}
@@ -0,0 +1,63 @@
+package feeds
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestLogTailLines(t *testing.T) {
+	utf16 := func(s string) string {
+		b, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().String(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	bom := string(utf16BOM)
+
+	tests := []struct {
+		name string
+		// writes are appended to the file one at a time, with the lines read after each
+		writes []string
+		want   [][]string
+	}{
+		{"utf8", []string{"first\nsec", "ond\n"}, [][]string{{"first"}, {"second"}}},
+		{"utf16", []string{bom + utf16("first\r\nsec"), utf16("ond\r\n")}, [][]string{{"first"}, {"second"}}},
+		// The encoding isn't known until the whole BOM has been written
+		{"utf16 split bom", []string{bom[:1], bom[1:] + utf16("first\r\n")}, [][]string{{}, {"first"}}},
+		{"utf8 one byte", []string{"f", "irst\n"}, [][]string{{}, {"first"}}},
+		// Half a character is left for the next read
+		{"utf16 split unit", []string{bom + utf16("ab")[:3], utf16("ab")[3:] + utf16("\n")}, [][]string{{}, {"ab"}}},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "log.txt")
+		tail := newLogTail(path)
+
+		for i, w := range tt.writes {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString(w); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			lines, _, err := tail.Lines()
+			if err != nil {
+				t.Fatalf("%s: write %d: %s", tt.name, i, err)
+			}
+			if len(lines) == 0 {
+				lines = []string{}
+			}
+			if !reflect.DeepEqual(lines, tt.want[i]) {
+				t.Errorf("%s: write %d got %q, want %q", tt.name, i, lines, tt.want[i])
+			}
+		}
+	}
+}
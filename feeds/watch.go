@@ -0,0 +1,257 @@
+package feeds
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/radovskyb/watcher"
+)
+
+type (
+	// logReader reads the log files in the directories of a dirWatch. Every call is made from the goroutine running
+	// the watch, so a reader can keep its state without locking.
+	logReader interface {
+		// attached is called once directories have started being watched, to read what is already in them
+		attached(dirs []string, now time.Time)
+		// detached is called when a directory is no longer watched, to forget the logs in it
+		detached(dir string)
+		// written is called with every log file that is created or written to
+		written(path string)
+		// prune is called every so often to forget the logs that are no longer being written
+		prune(now time.Time)
+	}
+
+	// dirWatch watches a set of log directories for log files being written. Directories that don't exist yet are
+	// waited for, as are directories that disappear, such as those on a drive that has been unmounted.
+	dirWatch struct {
+		// name prefixes the log messages of the watch
+		name string
+		w    *watcher.Watcher
+
+		// exists reports whether a log directory is there to be watched
+		exists func(dir string) bool
+		report func(error)
+
+		dirs []string
+		// attached holds the directories that are being watched, the others are waiting to appear
+		attached map[string]bool
+	}
+)
+
+func newDirWatch(name string, dirs []string, exists func(string) bool, report func(error)) *dirWatch {
+	w := watcher.New()
+	w.FilterOps(watcher.Create, watcher.Write)
+
+	return &dirWatch{
+		name:     name,
+		w:        w,
+		exists:   exists,
+		report:   report,
+		dirs:     append([]string(nil), dirs...),
+		attached: make(map[string]bool),
+	}
+}
+
+// run reads the logs until the context is cancelled. changed is signalled when the directories or anything else the
+// reader depends on have changed, and reconfigure is then called to pick up the changes. Both are nil for watches
+// that never change.
+func (dw *dirWatch) run(ctx context.Context, lr logReader, changed <-chan struct{}, reconfigure func(time.Time)) error {
+	written := make(chan string)
+	errs := make(chan error)
+	stopped := make(chan struct{})
+	// quit is closed if the watcher fails to start, as it will never close
+	quit := make(chan struct{})
+	go dw.pump(written, errs, quit, stopped)
+
+	// finished is closed once the goroutine has stopped reading
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		dw.attachWaiting(lr, time.Now())
+		dw.reportWaiting()
+
+		prune := time.NewTicker(10 * time.Minute)
+		defer prune.Stop()
+		poll := time.NewTicker(logDirPollInterval)
+		defer poll.Stop()
+		done := ctx.Done()
+		for {
+			select {
+			case now := <-prune.C:
+				lr.prune(now)
+			case now := <-poll.C:
+				dw.attachWaiting(lr, now)
+			case <-changed:
+				reconfigure(time.Now())
+				dw.reportWaiting()
+			case path := <-written:
+				lr.written(path)
+			case err := <-errs:
+				if err != watcher.ErrWatchedFileDeleted {
+					dw.report(err)
+					continue
+				}
+				// A directory has gone, most likely an unmounted drive, so wait for it to come back.
+				// The watcher has already stopped watching it.
+				for _, dir := range dw.dirs {
+					if _, serr := os.Stat(dir); dw.attached[dir] && serr != nil {
+						dw.forget(lr, dir)
+						dw.report(fmt.Errorf("%w: %s", WaitingForLogDir, dir))
+					}
+				}
+			case <-stopped:
+				return
+			case <-done:
+				// Close waits on the watcher, which may itself be waiting for an event to be taken, so it is
+				// called from its own goroutine. The pump keeps taking events until the watcher has closed.
+				done = nil
+				go func() {
+					// Closing a watcher that hasn't started yet does nothing, so keep at it until it has closed or
+					// failed to start
+					for {
+						dw.w.Close()
+						select {
+						case <-dw.w.Closed:
+							return
+						case <-quit:
+							return
+						case <-time.After(50 * time.Millisecond):
+						}
+					}
+				}()
+			}
+		}
+	}()
+
+	err := dw.w.Start(500 * time.Millisecond)
+	if err != nil {
+		close(quit)
+	}
+	// Nothing may be sent once the feed has returned
+	<-finished
+
+	return err
+}
+
+// pump takes every event and error from the watcher as soon as it is sent, and queues them up for run. The watcher
+// holds its lock while it waits for them to be taken, which would stop directories being added or removed while run
+// is busy. Writes to a file are merged while they wait, as reading a log takes in everything new in it. stopped is
+// closed once the watcher has closed, or once quit is closed.
+func (dw *dirWatch) pump(written chan<- string, errs chan<- error, quit <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	paths := make([]string, 0)
+	queued := make(map[string]bool)
+	failures := make([]error, 0)
+	for {
+		// Nothing is sent on a nil channel, so only what is queued is offered
+		var nextPath chan<- string
+		var path string
+		if len(paths) > 0 {
+			nextPath, path = written, paths[0]
+		}
+		var nextErr chan<- error
+		var err error
+		if len(failures) > 0 {
+			nextErr, err = errs, failures[0]
+		}
+
+		select {
+		case event := <-dw.w.Event:
+			if event.IsDir() || queued[event.Path] {
+				continue
+			}
+			queued[event.Path] = true
+			paths = append(paths, event.Path)
+		case e := <-dw.w.Error:
+			if !containsError(failures, e) {
+				failures = append(failures, e)
+			}
+		case nextPath <- path:
+			delete(queued, path)
+			paths = paths[1:]
+		case nextErr <- err:
+			failures = failures[1:]
+		case <-dw.w.Closed:
+			return
+		case <-quit:
+			return
+		}
+	}
+}
+
+// setDirs changes the directories that are watched. Directories that are no longer wanted are detached, and any new
+// ones that exist are attached, returning true if there were any.
+func (dw *dirWatch) setDirs(lr logReader, dirs []string, now time.Time) (found bool) {
+	for _, dir := range dw.dirs {
+		if !containsString(dirs, dir) {
+			log.Printf("%s: No longer watching %s", dw.name, dir)
+			dw.detach(lr, dir)
+		}
+	}
+	dw.dirs = append([]string(nil), dirs...)
+
+	return dw.attachWaiting(lr, now)
+}
+
+// attachWaiting starts watching any log directories that have appeared, returning true if there were any
+func (dw *dirWatch) attachWaiting(lr logReader, now time.Time) bool {
+	found := make([]string, 0)
+	for _, dir := range dw.dirs {
+		if dw.attached[dir] || !dw.exists(dir) {
+			continue
+		}
+		if err := dw.w.Add(dir); err != nil {
+			log.Printf("%s: failed to watch %s: %s", dw.name, dir, err)
+			continue
+		}
+		log.Printf("%s: Found the log directory %s", dw.name, dir)
+		dw.attached[dir] = true
+		found = append(found, dir)
+	}
+
+	if len(found) == 0 {
+		return false
+	}
+	lr.attached(found, now)
+	return true
+}
+
+// detach stops watching a log directory
+func (dw *dirWatch) detach(lr logReader, dir string) {
+	if dw.attached[dir] {
+		if err := dw.w.Remove(dir); err != nil {
+			log.Printf("%s: failed to stop watching %s: %s", dw.name, dir, err)
+		}
+	}
+	dw.forget(lr, dir)
+}
+
+// forget drops a log directory that is no longer watched
+func (dw *dirWatch) forget(lr logReader, dir string) {
+	delete(dw.attached, dir)
+	lr.detached(dir)
+}
+
+// reportWaiting sends an error for each log directory that has not appeared yet
+func (dw *dirWatch) reportWaiting() {
+	for _, dir := range dw.dirs {
+		if !dw.attached[dir] {
+			dw.report(fmt.Errorf("%w: %s", WaitingForLogDir, dir))
+		}
+	}
+}
+
+// containsError reports whether the same error is already in the list
+func containsError(list []error, err error) bool {
+	for _, e := range list {
+		if e == err || e.Error() == err.Error() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package feeds
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/radovskyb/watcher"
+)
+
+// nopReader reads nothing
+type nopReader struct{}
+
+func (nopReader) attached([]string, time.Time) {}
+func (nopReader) detached(string)              {}
+func (nopReader) written(string)               {}
+func (nopReader) prune(time.Time)              {}
+
+func TestDirWatchStartFails(t *testing.T) {
+	dw := newDirWatch("test", []string{t.TempDir()}, func(string) bool { return true }, func(error) {})
+
+	// A watcher that is already running can't be started again
+	go dw.w.Start(10 * time.Millisecond)
+	dw.w.Wait()
+	defer dw.w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := runtime.NumGoroutine()
+	result := make(chan error)
+	go func() {
+		result <- dw.run(ctx, nopReader{}, nil, nil)
+	}()
+
+	select {
+	case err := <-result:
+		if err != watcher.ErrWatcherRunning {
+			t.Errorf("run returned %v, want %v", err, watcher.ErrWatcherRunning)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return when the watcher failed to start")
+	}
+
+	// Nothing run started is left running
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines are still running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDirWatchStops(t *testing.T) {
+	dw := newDirWatch("test", []string{t.TempDir()}, func(string) bool { return true }, func(error) {})
+
+	// Cancelled straight away, before the watcher has had a chance to start
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := make(chan error)
+	go func() {
+		result <- dw.run(ctx, nopReader{}, nil, nil)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return once cancelled")
+	}
+}
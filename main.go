@@ -30,13 +30,22 @@ var (
 	css string
 )
 
+const (
+	feedChatlogIntel    = "chatlog intel"
+	feedChatlogLocal    = "chatlog local"
+	feedGamelogCombat   = "gamelog combat"
+	feedGamelogLocation = "gamelog location"
+)
+
 func basic() string {
 	return "Hello World!"
 }
 
 func main() {
 
-	ctx := context.Background()
+	// Everything runs until the window is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	cfg := config.NewConfig()
 	cfg.LoadConfig()
@@ -87,13 +96,14 @@ func main() {
 	// Will need to move this to backend eventually
 
 	lw := feeds.LogFeed{}
-	err = lw.SetLogDir(cfg.Data.ChatLogDirectory)
+	err = lw.SetLogDirs(cfg.Data.ChatLogDirectories)
 	if err != nil {
-		ui.errors = append(ui.errors, fmt.Sprintf("failed to set log directory: %s", err))
+		ui.errors = append(ui.errors, fmt.Sprintf("failed to set log directories: %s", err))
 		log.Printf("FATAL: SET YOUR LOG DIRECTORY: %s", err)
 	}
 	errs := make(chan error, 32)
 	lw.SetChatRooms(cfg.Data.Channels)
+	lw.SetBackfill(time.Duration(cfg.Data.BackfillMinutes) * time.Minute)
 	go func() {
 		for {
 			select {
@@ -109,12 +119,33 @@ func main() {
 
 	log.Println("Starting intel engine")
 
-	ie, err := engine.NewIntelEngine(ctx)
+	ie, err := engine.NewIntelEngine()
 	if err != nil {
 		ui.errors = append(ui.errors, fmt.Sprintf("failed to init intel engine: %s", err))
 		log.Fatalln(fmt.Errorf("failed to init intel engine: %w", err))
 	}
 
+	// Pick up where the last run left off
+	intelState := filepath.Join(cfg.GetConfigDirectory(), "spyglass_intel_state.json")
+	err = ie.LoadState(intelState)
+	if err != nil {
+		ui.errors = append(ui.errors, fmt.Sprintf("failed to load the previous intel: %s", err))
+		log.Printf("failed to load intel state: %s", err)
+	}
+
+	// The pilots' own names for systems, such as "1dq" for 1DQ1-A
+	aliases := filepath.Join(cfg.GetConfigDirectory(), "spyglass_aliases.json")
+	err = ie.LoadAliases(aliases)
+	if err != nil {
+		ui.errors = append(ui.errors, fmt.Sprintf("failed to load system aliases: %s", err))
+		log.Printf("failed to load system aliases: %s", err)
+	}
+
+	err = ie.Start(ctx)
+	if err != nil {
+		log.Fatalln(fmt.Errorf("failed to start intel engine: %w", err))
+	}
+
 	em, err := maps.NewEveMapper()
 	if err != nil {
 		ui.errors = append(ui.errors, fmt.Sprintf("failed to create mapper: %s", err))
@@ -127,21 +158,66 @@ func main() {
 	em.SetMap(cfg.Data.SelectedMap)
 
 	ie.SetClearWords(cfg.Data.ClearWords)
+	ie.AddActivitySource(&lw)
+	lw.SetSystemValidator(ie.Galaxy.SystemName)
+	ie.SetStaleAfter(time.Duration(cfg.Data.StaleChannelMinutes) * time.Minute)
 
 	// Set the log Watcher Feeders
 
 	reports, locations, _ := ie.GetFeeders()
-	go func() {
-		err = lw.Feed(ctx, reports, locations, errs)
-		if err != nil {
-			// TODO UNSAFE APPEND HERE
-			ui.errors = append(ui.errors, fmt.Sprintf("failed to start log feed: %s", err))
+
+	// Game logs track every characters location, even with Local chat logging turned off
+	gl := feeds.GamelogFeed{}
+	gl.SetLogDirs(cfg.Data.ChatLogDirectories)
+
+	fm := feeds.NewManager(reports, locations, errs)
+	fm.RegisterIntel(feedChatlogIntel, &lw)
+	fm.RegisterLocation(feedChatlogLocal, &lw)
+	fm.RegisterIntel(feedGamelogCombat, &gl)
+	fm.RegisterLocation(feedGamelogLocation, &gl)
+	fm.StartAll(ctx)
+
+	// Apply settings from the UI straight away
+	gamelogDirs := append([]string(nil), cfg.Data.ChatLogDirectories...)
+	cfg.OnChange(func(cd config.ConfigData) {
+		lw.SetLogDirs(cd.ChatLogDirectories)
+		lw.SetChatRooms(cd.Channels)
+		lw.SetBackfill(time.Duration(cd.BackfillMinutes) * time.Minute)
+		ie.SetClearWords(cd.ClearWords)
+		ie.SetStaleAfter(time.Duration(cd.StaleChannelMinutes) * time.Minute)
+
+		// Switching maps rebuilds the map graph, so only do it when the map has changed
+		if cd.SelectedMap != em.GetMap() {
+			if err := ie.SetCurrentMap(cd.SelectedMap); err != nil {
+				log.Printf("failed to set the intel map to %s: %s", cd.SelectedMap, err)
+			}
+			if err := em.SetMap(cd.SelectedMap); err != nil {
+				log.Printf("failed to show the map %s: %s", cd.SelectedMap, err)
+			}
 		}
-	}()
+
+		// Restarting the game log feeds forgets where every character is until they move, so only do it when the
+		// directories have changed
+		if !sameStrings(cd.ChatLogDirectories, gamelogDirs) {
+			gamelogDirs = append([]string(nil), cd.ChatLogDirectories...)
+			for _, name := range []string{feedGamelogCombat, feedGamelogLocation} {
+				fm.Stop(name)
+			}
+			gl.SetLogDirs(gamelogDirs)
+			for _, name := range []string{feedGamelogCombat, feedGamelogLocation} {
+				err := fm.Start(ctx, name)
+				if err != nil {
+					log.Printf("failed to restart %s: %s", name, err)
+				}
+			}
+		}
+	})
 
 	//START FRONTEND
 
 	ui.intelEngine = ie
+	ui.feedManager = fm
+	ui.logFeed = &lw
 
 	app := wails.CreateApp(&wails.AppConfig{
 		Width:     1024,
@@ -162,6 +238,18 @@ func main() {
 	log.Println("APP RUN")
 
 	err = app.Run()
+
+	// The window has closed, stop the feeds first so the engine can take in everything they had already sent
+	log.Println("APP SHUTDOWN")
+	fm.StopAll()
+	if err := ie.Stop(); err != nil {
+		log.Printf("failed to stop intel engine: %s", err)
+	}
+	if err := ie.SaveState(intelState); err != nil {
+		log.Printf("failed to save intel state: %s", err)
+	}
+	cancel()
+
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -174,19 +262,35 @@ type (
 		runtime *wails.Runtime
 		errors  []string
 
+		// stopUpdates is closed when the window closes, to stop the UI update ticker
+		stopUpdates chan struct{}
+
 		intelEngine *engine.IntelEngine
+		feedManager *feeds.Manager
+		logFeed     *feeds.LogFeed
 	}
 )
 
 func (ui *UserInterface) WailsInit(runtime *wails.Runtime) error {
+	ui.stopUpdates = make(chan struct{})
+	stop := ui.stopUpdates
+
 	go func() {
-		time.Sleep(3 * time.Second)
+		select {
+		case <-time.After(3 * time.Second):
+		case <-stop:
+			return
+		}
+
 		t := time.NewTicker(1000 * time.Millisecond)
+		defer t.Stop()
 		for {
 			select {
 			case <-t.C:
 				log.Println("Updating UI")
 				runtime.Events.Emit("ui_update")
+			case <-stop:
+				return
 			}
 		}
 	}()
@@ -194,10 +298,48 @@ func (ui *UserInterface) WailsInit(runtime *wails.Runtime) error {
 	return nil
 }
 
+// WailsShutdown is called by Wails when the window closes
+func (ui *UserInterface) WailsShutdown() {
+	if ui.stopUpdates != nil {
+		close(ui.stopUpdates)
+		ui.stopUpdates = nil
+	}
+}
+
 func (ui *UserInterface) ReadErrorList() []string {
 	return ui.errors
 }
 
+// ReadWarningList returns problems that are not errors, such as intel channels that have gone quiet
+func (ui *UserInterface) ReadWarningList() []string {
+	return ui.intelEngine.Warnings()
+}
+
 func (ui *UserInterface) GetIntelMessages() []string {
 	return ui.intelEngine.GetIntelMessages()
 }
+
+func (ui *UserInterface) GetHostiles() []engine.Hostile {
+	return ui.intelEngine.Hostiles()
+}
+
+func (ui *UserInterface) GetFeedStatus() []feeds.FeederStatus {
+	return ui.feedManager.Status()
+}
+
+func (ui *UserInterface) GetChatChannels() ([]feeds.ChannelInfo, error) {
+	return ui.logFeed.ListChannels()
+}
+
+// sameStrings is true when both lists hold the same strings in the same order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
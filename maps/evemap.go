@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	svg "github.com/ajstarks/svgo"
@@ -18,10 +19,13 @@ import (
 
 type (
 	EveMapper struct {
+		// mu guards the current map and its connections, which can be changed from the settings while it is drawn
+		mu          sync.RWMutex
 		currentMap  string
-		definitions spyglassMapsCollection
 		connections []string
 
+		definitions spyglassMapsCollection
+
 		intelResource engine.IntelResource
 	}
 
@@ -115,6 +119,8 @@ func (em *EveMapper) SetMap(m string) error {
 	if mp, ok = em.definitions[m]; !ok {
 		return errMapNotDefined
 	}
+	em.mu.Lock()
+	defer em.mu.Unlock()
 	em.currentMap = m
 
 	// Load connections from intel resource if it exists
@@ -144,6 +150,8 @@ func (em *EveMapper) SetMap(m string) error {
 }
 
 func (em *EveMapper) GetMap() string {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
 	return em.currentMap
 }
 
@@ -155,10 +163,14 @@ func (em *EveMapper) GetCurrentMapSVG() string {
 	const systemHeight = 22
 	const systemRounded = 10
 
+	em.mu.RLock()
+	currentMap, connections := em.currentMap, em.connections
+	em.mu.RUnlock()
+
 	var mp spyglassMap
 
 	for s, m := range em.definitions {
-		if s == em.currentMap {
+		if s == currentMap {
 			mp = m
 			break
 		}
@@ -166,10 +178,12 @@ func (em *EveMapper) GetCurrentMapSVG() string {
 
 	statusi := make(map[int32]uint8)
 	timei := make(map[int32]time.Time)
+	shipsi := make(map[int32][]string)
 
 	if em.intelResource != nil {
 		statusi = em.intelResource.Status()
 		timei = em.intelResource.LastUpdated()
+		shipsi = em.intelResource.Ships()
 	}
 
 	var buf bytes.Buffer
@@ -180,7 +194,7 @@ func (em *EveMapper) GetCurrentMapSVG() string {
 	// First draw all of the connections so that they are beneath all other things. Keep them in their own group
 
 	canvas.Gid("jumps")
-	for _, con := range em.connections {
+	for _, con := range connections {
 		sp := strings.Split(con, "-")
 		if len(sp) != 2 {
 			continue
@@ -248,6 +262,11 @@ func (em *EveMapper) GetCurrentMapSVG() string {
 		}
 		canvas.Roundrect(s.X, s.Y, systemWidth, systemHeight, rnd, rnd, style)
 
+		// List the reported ships when hovering over the system
+		if ships, ok := shipsi[s.ID]; ok && len(ships) > 0 {
+			canvas.Title(strings.Join(ships, ", "))
+		}
+
 		t, tok := timei[s.ID]
 
 		//	create the system name text